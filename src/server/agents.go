@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const agentOnlineWindow = 60 * time.Second
+
+var errAgentNotFound = errors.New("agent not registered")
+
+type Agent struct {
+	ID       string    `json:"id"`
+	Hostname string    `json:"hostname"`
+	OS       string    `json:"os"`
+	Version  string    `json:"version"`
+	LastSeen time.Time `json:"last_seen"`
+	Online   bool      `json:"online"`
+	// Apps are the app names whose rules the agent enforces, as last
+	// reported at registration or heartbeat.
+	Apps []string `json:"apps,omitempty"`
+}
+
+// heartbeatRequest is the optional heartbeat body. A nil Apps keeps the
+// agent's current list.
+type heartbeatRequest struct {
+	Apps []string `json:"apps"`
+}
+
+// agentPolicy is everything an agent needs to enforce: the effective rule of
+// each of its apps that has one, and the default rule for the rest. Hash
+// changes whenever any of them does.
+type agentPolicy struct {
+	AgentID string         `json:"agent_id"`
+	Hash    string         `json:"hash"`
+	Rules   []FirewallRule `json:"rules"`
+	Default *FirewallRule  `json:"default,omitempty"`
+}
+
+func (s *CentralServer) HandleRegisterAgent(w http.ResponseWriter, r *http.Request) {
+	var agent Agent
+	err := json.NewDecoder(r.Body).Decode(&agent)
+	if err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if agent.ID == "" {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Agent id is required")
+		return
+	}
+	agent.LastSeen = time.Now().UTC()
+
+	s.agentMu.Lock()
+	s.agents[agent.ID] = agent
+	s.agentMu.Unlock()
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *CentralServer) HandleAgentHeartbeat(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var req heartbeatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeDecodeError(w, err)
+		return
+	}
+
+	s.agentMu.Lock()
+	agent, ok := s.agents[id]
+	if ok {
+		agent.LastSeen = time.Now().UTC()
+		if req.Apps != nil {
+			agent.Apps = req.Apps
+		}
+		s.agents[id] = agent
+	}
+	s.agentMu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, CodeAgentNotFound, "Agent not registered")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *CentralServer) HandleListAgents(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+
+	s.agentMu.RLock()
+	agents := make([]Agent, 0, len(s.agents))
+	for _, agent := range s.agents {
+		agent.Online = now.Sub(agent.LastSeen) <= agentOnlineWindow
+		agents = append(agents, agent)
+	}
+	s.agentMu.RUnlock()
+
+	sort.Slice(agents, func(i, j int) bool { return agents[i].ID < agents[j].ID })
+	json.NewEncoder(w).Encode(agents)
+}
+
+// AgentPolicy returns the policy for the agent with the given id.
+func (s *CentralServer) AgentPolicy(ctx context.Context, id string) (agentPolicy, error) {
+	s.agentMu.RLock()
+	agent, ok := s.agents[id]
+	s.agentMu.RUnlock()
+	if !ok {
+		return agentPolicy{}, errAgentNotFound
+	}
+
+	policy := agentPolicy{AgentID: id, Rules: []FirewallRule{}}
+	apps := slices.Clone(agent.Apps)
+	slices.Sort(apps)
+	apps = slices.Compact(apps)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, app := range apps {
+		if app == DefaultRuleName {
+			continue
+		}
+		rule, err := s.enabledRuleLocked(ctx, app)
+		if errors.Is(err, ErrRuleNotFound) {
+			continue
+		}
+		if err != nil {
+			return policy, err
+		}
+		if rule, err = s.effectiveLocked(ctx, rule); err != nil {
+			return policy, err
+		}
+		policy.Rules = append(policy.Rules, rule)
+	}
+	if rule, err := s.enabledRuleLocked(ctx, DefaultRuleName); err == nil {
+		if rule, err = s.effectiveLocked(ctx, rule); err != nil {
+			return policy, err
+		}
+		policy.Default = &rule
+	} else if !errors.Is(err, ErrRuleNotFound) {
+		return policy, err
+	}
+
+	data, err := json.Marshal(struct {
+		Rules   []FirewallRule `json:"rules"`
+		Default *FirewallRule  `json:"default"`
+	}{policy.Rules, policy.Default})
+	if err != nil {
+		return policy, err
+	}
+	sum := sha256.Sum256(data)
+	policy.Hash = hex.EncodeToString(sum[:])
+	return policy, nil
+}
+
+func (s *CentralServer) HandleAgentPolicy(w http.ResponseWriter, r *http.Request) {
+	policy, err := s.AgentPolicy(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, errAgentNotFound) {
+		writeError(w, http.StatusNotFound, CodeAgentNotFound, "Agent not registered")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+	writeETagged(w, r, policy)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAgentPolicyHash(t *testing.T) {
+	h := newTestServer(t).Handler()
+	putRule(t, h, FirewallRule{AppName: "base", Template: true, AllowedDomains: []string{"updates.example"}, Enabled: true})
+	putRule(t, h, FirewallRule{AppName: "curl", Templates: []string{"base"}, AllowedDomains: []string{"example.com"}, Enabled: true})
+	putRule(t, h, FirewallRule{AppName: "git", AllowedDomains: []string{"github.com"}, Enabled: true})
+	expect(t, call(t, h, "POST", "/agents/register", Agent{ID: "host-1", Apps: []string{"curl", "wget"}}), http.StatusCreated)
+
+	policy := func() agentPolicy {
+		t.Helper()
+		rec := call(t, h, "GET", "/v1/agents/host-1/policy", nil)
+		expect(t, rec, http.StatusOK)
+		var p agentPolicy
+		decode(t, rec, &p)
+		return p
+	}
+	first := policy()
+	if len(first.Rules) != 1 || first.Rules[0].AppName != "curl" || len(first.Rules[0].AllowedDomains) != 2 || first.Default != nil {
+		t.Fatalf("policy = %+v, want curl's effective rule alone", first)
+	}
+	if again := policy(); again.Hash != first.Hash {
+		t.Errorf("hash changed from %s to %s with no update", first.Hash, again.Hash)
+	}
+
+	putRule(t, h, FirewallRule{AppName: "git", AllowedDomains: []string{"gitlab.com"}, Enabled: true})
+	if got := policy(); got.Hash != first.Hash {
+		t.Errorf("updating an app the agent doesn't run changed the hash")
+	}
+
+	hash := first.Hash
+	for _, rule := range []FirewallRule{
+		{AppName: "curl", Templates: []string{"base"}, AllowedDomains: []string{"example.org"}, Enabled: true},
+		{AppName: "base", Template: true, AllowedDomains: []string{"mirror.example"}, Enabled: true},
+		{AppName: DefaultRuleName, Enabled: true},
+	} {
+		putRule(t, h, rule)
+		got := policy()
+		if got.Hash == hash {
+			t.Errorf("hash unchanged after updating %s", rule.AppName)
+		}
+		hash = got.Hash
+	}
+
+	expect(t, call(t, h, "GET", "/v1/agents/host-2/policy", nil), http.StatusNotFound)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ErrorCode is the stable, machine-readable part of an error response.
+// Messages may change; codes don't.
+type ErrorCode string
+
+const (
+	CodeInvalidRequest       ErrorCode = "INVALID_REQUEST"
+	CodeValidationFailed     ErrorCode = "VALIDATION_FAILED"
+	CodeBodyTooLarge         ErrorCode = "BODY_TOO_LARGE"
+	CodeUnsupportedMediaType ErrorCode = "UNSUPPORTED_MEDIA_TYPE"
+	CodeUnauthorized         ErrorCode = "UNAUTHORIZED"
+	CodeOriginNotAllowed     ErrorCode = "ORIGIN_NOT_ALLOWED"
+	CodeNotFound             ErrorCode = "NOT_FOUND"
+	CodeMethodNotAllowed     ErrorCode = "METHOD_NOT_ALLOWED"
+	CodeRuleNotFound         ErrorCode = "RULE_NOT_FOUND"
+	CodeAgentNotFound        ErrorCode = "AGENT_NOT_FOUND"
+	CodeVersionConflict      ErrorCode = "VERSION_CONFLICT"
+	CodeTemplateInUse        ErrorCode = "TEMPLATE_IN_USE"
+	CodeIdempotencyKeyReused ErrorCode = "IDEMPOTENCY_KEY_REUSED"
+	CodeRequestInProgress    ErrorCode = "REQUEST_IN_PROGRESS"
+	CodeRateLimited          ErrorCode = "RATE_LIMITED"
+	CodeReadOnly             ErrorCode = "READ_ONLY"
+	CodeTimeout              ErrorCode = "TIMEOUT"
+	CodeUnavailable          ErrorCode = "UNAVAILABLE"
+	CodeInternal             ErrorCode = "INTERNAL"
+)
+
+var errorCodes = []ErrorCode{
+	CodeInvalidRequest, CodeValidationFailed, CodeBodyTooLarge, CodeUnsupportedMediaType, CodeUnauthorized,
+	CodeOriginNotAllowed, CodeNotFound, CodeMethodNotAllowed, CodeRuleNotFound,
+	CodeAgentNotFound, CodeVersionConflict, CodeTemplateInUse,
+	CodeIdempotencyKeyReused, CodeRequestInProgress, CodeRateLimited,
+	CodeReadOnly, CodeTimeout, CodeUnavailable, CodeInternal,
+}
+
+// APIError is the body of every error response. Errors lists per-item
+// problems when a batch is rejected as a whole.
+type APIError struct {
+	Code    ErrorCode     `json:"code"`
+	Message string        `json:"message"`
+	Errors  []importError `json:"errors,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return string(e.Code) + ": " + e.Message
+}
+
+// writeError sends an APIError in place of http.Error.
+func writeError(w http.ResponseWriter, status int, code ErrorCode, msg string) {
+	writeAPIError(w, status, &APIError{Code: code, Message: msg})
+}
+
+func writeAPIError(w http.ResponseWriter, status int, e *APIError) {
+	h := w.Header()
+	// An error replaces whatever the handler had prepared, as http.Error does.
+	h.Del("Content-Length")
+	h.Del("ETag")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(e)
+}
+
+// errorBody encodes an APIError for writers that only take a fixed string,
+// such as http.TimeoutHandler.
+func errorBody(code ErrorCode, msg string) string {
+	data, _ := json.Marshal(&APIError{Code: code, Message: msg})
+	return string(data)
+}
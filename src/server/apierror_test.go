@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestErrorCodes(t *testing.T) {
+	h := newTestServer(t).Handler()
+	putRule(t, h, FirewallRule{AppName: "curl", Enabled: true})
+
+	tests := []struct {
+		method, path string
+		body         any
+		status       int
+		code         ErrorCode
+	}{
+		{"GET", "/v1/rule/wget", nil, http.StatusNotFound, CodeRuleNotFound},
+		{"DELETE", "/v1/rule/wget", nil, http.StatusNotFound, CodeRuleNotFound},
+		{"POST", "/v1/rule", FirewallRule{AppName: "curl", AllowedIPs: []string{"10.0.0.300"}}, http.StatusBadRequest, CodeValidationFailed},
+		{"POST", "/v1/rule", FirewallRule{AppName: "curl", Version: 7}, http.StatusConflict, CodeVersionConflict},
+		{"GET", "/v1/nowhere", nil, http.StatusNotFound, CodeNotFound},
+	}
+	for _, tt := range tests {
+		rec := call(t, h, tt.method, tt.path, tt.body)
+		var got APIError
+		decode(t, rec, &got)
+		if rec.Code != tt.status || got.Code != tt.code || got.Message == "" {
+			t.Errorf("%s %s: %d %+v, want %d %s", tt.method, tt.path, rec.Code, got, tt.status, tt.code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s %s: Content-Type = %q", tt.method, tt.path, ct)
+		}
+	}
+}
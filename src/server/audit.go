@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// AuditEntry records one change to a rule. Before is nil for creates and
+// After is nil for deletes.
+type AuditEntry struct {
+	Timestamp time.Time     `json:"timestamp"`
+	Actor     string        `json:"actor"`
+	Action    string        `json:"action"`
+	AppName   string        `json:"app_name"`
+	Before    *FirewallRule `json:"before"`
+	After     *FirewallRule `json:"after"`
+}
+
+type AuditFilter struct {
+	AppName string
+	Since   time.Time
+	Until   time.Time
+}
+
+// recordAuditLocked appends an audit entry. The rule change has already been
+// committed, so a failure here is logged instead of failing the request.
+// Callers hold s.mu.
+func (s *CentralServer) recordAuditLocked(ctx context.Context, action string, before, after *FirewallRule) {
+	entry := AuditEntry{
+		Timestamp: time.Now().UTC(),
+		Actor:     Actor(ctx),
+		Action:    action,
+		Before:    before,
+		After:     after,
+	}
+	if after != nil {
+		entry.AppName = after.AppName
+	} else if before != nil {
+		entry.AppName = before.AppName
+	}
+	if err := s.store.AppendAudit(context.WithoutCancel(ctx), entry); err != nil {
+		contextLogger(ctx).Error("recording audit entry", "app_name", entry.AppName, "action", action, "err", err)
+	}
+}
+
+func parseTimeParam(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	return t.UTC(), err
+}
+
+func (s *CentralServer) HandleListAudit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	since, err := parseTimeParam(q.Get("since"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Invalid since: "+err.Error())
+		return
+	}
+	until, err := parseTimeParam(q.Get("until"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Invalid until: "+err.Error())
+		return
+	}
+
+	entries, err := s.store.ListAudit(r.Context(), AuditFilter{AppName: q.Get("app_name"), Since: since, Until: until})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+	json.NewEncoder(w).Encode(entries)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDeleteIsAudited(t *testing.T) {
+	h := newTestServer(t).Handler()
+	rule := putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"example.com"}, Enabled: true})
+	expect(t, call(t, h, "DELETE", "/v1/rule/curl", nil), http.StatusNoContent)
+
+	rec := call(t, h, "GET", "/v1/audit?app_name=curl", nil)
+	expect(t, rec, http.StatusOK)
+	var entries []AuditEntry
+	decode(t, rec, &entries)
+	if len(entries) != 2 {
+		t.Fatalf("%d audit entries, want create and delete", len(entries))
+	}
+	got := entries[0]
+	if got.Action != AuditDelete || got.Actor != defaultActor || got.After != nil {
+		t.Errorf("newest entry = %+v, want a delete by %s with no after", got, defaultActor)
+	}
+	if got.Before == nil || got.Before.Version != rule.Version || got.Before.AllowedDomains[0] != "example.com" {
+		t.Errorf("before = %+v, want the deleted rule", got.Before)
+	}
+	if entries[1].Action != AuditCreate || entries[1].Before != nil {
+		t.Errorf("oldest entry = %+v, want the create", entries[1])
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// defaultActor is the subject recorded for requests made with AuthToken.
+const defaultActor = "admin"
+
+// Actor returns the authenticated subject for the request, or "" when the
+// route wasn't authenticated.
+func Actor(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey).(string)
+	return actor
+}
+
+// authenticate maps a bearer token to its subject. Every configured token is
+// compared so the time taken doesn't reveal which one matched.
+func (s *CentralServer) authenticate(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	s.liveMu.RLock()
+	defer s.liveMu.RUnlock()
+	subject, ok := "", false
+	if s.AuthToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.AuthToken)) == 1 {
+		subject, ok = defaultActor, true
+	}
+	for candidate, name := range s.AuthTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(candidate)) == 1 {
+			subject, ok = name, true
+		}
+	}
+	return subject, ok
+}
+
+// RequireAuth rejects requests whose bearer token isn't AuthToken or one of
+// AuthTokens. With no tokens configured every request is rejected rather than
+// silently allowing writes.
+func (s *CentralServer) RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		subject, authed := s.authenticate(token)
+		if !ok || !authed {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="firewall"`)
+			writeError(w, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), actorKey, subject)))
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRequireAuth(t *testing.T) {
+	s := newTestServer(t)
+	s.AuthTokens = map[string]string{"ci-token": "ci"}
+	h := s.Handler()
+	body := `{"app_name": "curl", "allowed_domains": ["example.com"]}`
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"valid token", "Bearer " + testToken, http.StatusCreated},
+		{"subject token", "Bearer ci-token", http.StatusCreated},
+		{"missing header", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer nope", http.StatusUnauthorized},
+		{"not a bearer", "Basic " + testToken, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest(t, "POST", "/v1/rule", body)
+			req.Header.Del("Authorization")
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := serve(h, req)
+			expect(t, rec, tt.want)
+			if tt.want == http.StatusUnauthorized {
+				var apiErr APIError
+				decode(t, rec, &apiErr)
+				if apiErr.Code != CodeUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+					t.Errorf("error = %+v, WWW-Authenticate %q", apiErr, rec.Header().Get("WWW-Authenticate"))
+				}
+			}
+		})
+	}
+}
+
+func TestAuthReads(t *testing.T) {
+	for _, authReads := range []bool{false, true} {
+		s := newTestServer(t)
+		s.AuthReads = authReads
+		req := newRequest(t, "GET", "/v1/rules", nil)
+		req.Header.Del("Authorization")
+		want := http.StatusOK
+		if authReads {
+			want = http.StatusUnauthorized
+		}
+		if rec := serve(s.Handler(), req); rec.Code != want {
+			t.Errorf("AuthReads=%v: anonymous GET status = %d, want %d", authReads, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	for header, want := range map[string]bool{
+		"":                    false,
+		"gzip":                true,
+		"deflate, gzip;q=0.5": true,
+		"gzip;q=0":            false,
+		"br, identity":        false,
+		"GZIP":                false,
+	} {
+		if got := acceptsGzip(header); got != want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestCompressLargeResponses(t *testing.T) {
+	h := newTestServer(t).Handler()
+	for port := 1; port <= 50; port++ {
+		entry := NetworkLog{AppName: "curl", RemoteDomain: "cdn.example.com", Port: port, Action: ActionAllowed}
+		expect(t, call(t, h, "POST", "/v1/logs", entry), http.StatusCreated)
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		wantGzip       bool
+	}{
+		{"large with gzip", "/v1/logs", "gzip", true},
+		{"large without gzip", "/v1/logs", "", false},
+		{"refused by q=0", "/v1/logs", "gzip;q=0", false},
+		{"small with gzip", "/v1/logs?limit=1", "gzip", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest(t, "GET", tt.path, nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := serve(h, req)
+			expect(t, rec, http.StatusOK)
+			if !strings.Contains(rec.Header().Get("Vary"), "Accept-Encoding") {
+				t.Error("Vary does not name Accept-Encoding")
+			}
+			var body io.Reader = rec.Body
+			if gzipped := rec.Header().Get("Content-Encoding") == "gzip"; gzipped != tt.wantGzip {
+				t.Fatalf("gzipped = %v, want %v", gzipped, tt.wantGzip)
+			} else if gzipped {
+				zr, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = zr
+			}
+			var page logPage
+			if err := json.NewDecoder(body).Decode(&page); err != nil {
+				t.Fatal(err)
+			}
+			if page.Total != 50 {
+				t.Errorf("decoded %d logs, want 50", page.Total)
+			}
+		})
+	}
+}
+
+func TestCompressWeakensETag(t *testing.T) {
+	h := newTestServer(t).Handler()
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{strings.Repeat("a", 2000) + ".example"}, Enabled: true})
+	req := newRequest(t, "GET", "/v1/rule/curl", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := serve(h, req)
+	if rec.Header().Get("Content-Encoding") != "gzip" || !strings.HasPrefix(rec.Header().Get("ETag"), `W/"`) {
+		t.Errorf("Content-Encoding %q, ETag %q; want gzip with a weak tag", rec.Header().Get("Content-Encoding"), rec.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds every server setting. LoadConfig fills it from defaults, then
+// an optional JSON file, then environment variables, each overriding the last.
+type Config struct {
+	Addr           string            `json:"addr"`
+	GRPCAddr       string            `json:"grpc_addr"`
+	TLSCertFile    string            `json:"tls_cert_file"`
+	TLSKeyFile     string            `json:"tls_key_file"`
+	AuthToken      string            `json:"auth_token"`
+	AuthTokens     map[string]string `json:"auth_tokens"`
+	AuthReads      bool              `json:"auth_reads"`
+	CORSOrigins    []string          `json:"cors_origins"`
+	MaxBodyBytes   int64             `json:"max_body_bytes"`
+	RequestTimeout Duration          `json:"request_timeout"`
+	HistoryLimit   int               `json:"history_limit"`
+	DBPath         string            `json:"db_path"`
+
+	// AdminAddr, when set, is the only listener for rule changes and admin
+	// endpoints; Addr then serves just reads, decisions and agent traffic,
+	// and GRPCAddr just GetRule and WatchRules.
+	AdminAddr string `json:"admin_addr"`
+
+	// StorageDriver picks the rule store: "sqlite" uses DBPath, "postgres"
+	// connects to DatabaseURL and archives stored logs there as well.
+	StorageDriver string `json:"storage_driver"`
+	DatabaseURL   string `json:"database_url"`
+
+	LogLevel     string   `json:"log_level"`
+	LogRetention Duration `json:"log_retention"`
+	MaxLogs      int      `json:"max_logs"`
+
+	// LogDedupWindow collapses identical logs within it into one record with
+	// a count. Zero stores every log as received. Webhooks and the log
+	// archive see only the first log of each record; the repeats folded
+	// into it are counted but not sent on.
+	LogDedupWindow Duration `json:"log_dedup_window"`
+
+	// DNSCacheTTL is how long resolved rule domains are trusted. A domain is
+	// first resolved in the background when an IP-only connection is checked
+	// against it, and matches once the answer is in. Zero turns off matching
+	// IP-only connections against domain rules.
+	DNSCacheTTL Duration `json:"dns_cache_ttl"`
+
+	// GeoIPCountryDB and GeoIPASNDB are MaxMind GeoLite2 database files used
+	// to tag logs with country and ASN. Either may be left empty.
+	GeoIPCountryDB string `json:"geoip_country_db"`
+	GeoIPASNDB     string `json:"geoip_asn_db"`
+
+	// Variables fill ${NAME} placeholders in rules' allowed domains and IPs,
+	// so one rule definition can serve several environments.
+	Variables map[string]string `json:"variables"`
+
+	// Webhooks are notified of every blocked connection that is logged,
+	// except repeats folded into an earlier record by LogDedupWindow.
+	Webhooks []Webhook `json:"webhooks"`
+
+	// HighRiskDomains and HighRiskIPs mark blocked logs to those remotes as
+	// high severity. They take the same entries as rule lists.
+	HighRiskDomains []string `json:"high_risk_domains"`
+	HighRiskIPs     []string `json:"high_risk_ips"`
+
+	// LogRateLimit is the sustained POST /logs rate per client IP in requests
+	// per second, with bursts up to LogRateBurst. Zero disables limiting.
+	LogRateLimit float64 `json:"log_rate_limit"`
+	LogRateBurst int     `json:"log_rate_burst"`
+
+	// IdempotencyTTL is how long an Idempotency-Key on POST /rule is
+	// remembered. Zero ignores the header.
+	IdempotencyTTL Duration `json:"idempotency_ttl"`
+}
+
+// Duration is a time.Duration written as a string such as "72h" in config files.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Addr:           ":8080",
+		GRPCAddr:       ":9090",
+		DBPath:         "firewall.db",
+		StorageDriver:  StorageSQLite,
+		LogLevel:       "info",
+		MaxLogs:        defaultMaxLogs,
+		MaxBodyBytes:   defaultMaxBodyBytes,
+		RequestTimeout: Duration(defaultRequestTimeout),
+		HistoryLimit:   defaultHistoryLimit,
+		DNSCacheTTL:    Duration(defaultDNSCacheTTL),
+		LogRateLimit:   defaultLogRate,
+		LogRateBurst:   defaultLogBurst,
+		IdempotencyTTL: Duration(defaultIdempotencyTTL),
+	}
+}
+
+var configEnv = []struct {
+	name  string
+	apply func(c *Config, v string) error
+}{
+	{"LISTEN_ADDR", func(c *Config, v string) error { c.Addr = v; return nil }},
+	{"GRPC_ADDR", func(c *Config, v string) error { c.GRPCAddr = v; return nil }},
+	{"ADMIN_LISTEN_ADDR", func(c *Config, v string) error { c.AdminAddr = v; return nil }},
+	{"TLS_CERT_FILE", func(c *Config, v string) error { c.TLSCertFile = v; return nil }},
+	{"TLS_KEY_FILE", func(c *Config, v string) error { c.TLSKeyFile = v; return nil }},
+	{"AUTH_TOKEN", func(c *Config, v string) error { c.AuthToken = v; return nil }},
+	{"AUTH_REQUIRE_READS", func(c *Config, v string) (err error) { c.AuthReads, err = strconv.ParseBool(v); return }},
+	{"DB_PATH", func(c *Config, v string) error { c.DBPath = v; return nil }},
+	{"STORAGE_DRIVER", func(c *Config, v string) error { c.StorageDriver = v; return nil }},
+	{"DATABASE_URL", func(c *Config, v string) error { c.DatabaseURL = v; return nil }},
+	{"LOG_LEVEL", func(c *Config, v string) error { c.LogLevel = v; return nil }},
+	{"LOG_RETENTION", func(c *Config, v string) error { return c.LogRetention.UnmarshalJSON(strconv.AppendQuote(nil, v)) }},
+	{"LOG_DEDUP_WINDOW", func(c *Config, v string) error { return c.LogDedupWindow.UnmarshalJSON(strconv.AppendQuote(nil, v)) }},
+	{"DNS_CACHE_TTL", func(c *Config, v string) error { return c.DNSCacheTTL.UnmarshalJSON(strconv.AppendQuote(nil, v)) }},
+	{"GEOIP_COUNTRY_DB", func(c *Config, v string) error { c.GeoIPCountryDB = v; return nil }},
+	{"GEOIP_ASN_DB", func(c *Config, v string) error { c.GeoIPASNDB = v; return nil }},
+	{"RULE_VARIABLES", func(c *Config, v string) error {
+		c.Variables = map[string]string{}
+		for _, pair := range splitList(v) {
+			name, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("%q is not NAME=value", pair)
+			}
+			c.Variables[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+		return nil
+	}},
+	{"WEBHOOK_URLS", func(c *Config, v string) error {
+		c.Webhooks = nil
+		for _, url := range splitList(v) {
+			c.Webhooks = append(c.Webhooks, Webhook{URL: url})
+		}
+		return nil
+	}},
+	{"CORS_ORIGINS", func(c *Config, v string) error { c.CORSOrigins = splitList(v); return nil }},
+	{"HIGH_RISK_DOMAINS", func(c *Config, v string) error { c.HighRiskDomains = splitList(v); return nil }},
+	{"HIGH_RISK_IPS", func(c *Config, v string) error { c.HighRiskIPs = splitList(v); return nil }},
+	{"HISTORY_LIMIT", func(c *Config, v string) (err error) { c.HistoryLimit, err = strconv.Atoi(v); return }},
+	{"REQUEST_TIMEOUT", func(c *Config, v string) error { return c.RequestTimeout.UnmarshalJSON(strconv.AppendQuote(nil, v)) }},
+	{"MAX_BODY_BYTES", func(c *Config, v string) (err error) { c.MaxBodyBytes, err = strconv.ParseInt(v, 10, 64); return }},
+	{"MAX_LOGS", func(c *Config, v string) (err error) { c.MaxLogs, err = strconv.Atoi(v); return }},
+	{"LOG_RATE_LIMIT", func(c *Config, v string) (err error) { c.LogRateLimit, err = strconv.ParseFloat(v, 64); return }},
+	{"IDEMPOTENCY_TTL", func(c *Config, v string) error { return c.IdempotencyTTL.UnmarshalJSON(strconv.AppendQuote(nil, v)) }},
+	{"LOG_RATE_BURST", func(c *Config, v string) (err error) { c.LogRateBurst, err = strconv.Atoi(v); return }},
+}
+
+// splitList parses a comma-separated env value, dropping empty items.
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// LoadConfig reads path (skipped when empty) and applies environment overrides.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("parsing %s: %w", path, err)
+		}
+	}
+	for _, env := range configEnv {
+		v, ok := os.LookupEnv(env.name)
+		if !ok || v == "" {
+			continue
+		}
+		if err := env.apply(&cfg, v); err != nil {
+			return cfg, fmt.Errorf("invalid %s: %w", env.name, err)
+		}
+	}
+	return cfg, cfg.Validate()
+}
+
+func (c Config) Validate() error {
+	var errs []error
+	if c.Addr == "" {
+		errs = append(errs, errors.New("addr must not be empty"))
+	}
+	if c.AdminAddr != "" && c.AdminAddr == c.Addr {
+		errs = append(errs, errors.New("admin_addr must differ from addr"))
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("tls_cert_file and tls_key_file must be set together"))
+	}
+	switch c.StorageDriver {
+	case StorageSQLite:
+		if c.DBPath == "" {
+			errs = append(errs, errors.New("db_path must not be empty"))
+		}
+	case StoragePostgres:
+		if c.DatabaseURL == "" {
+			errs = append(errs, errors.New("database_url is required for the postgres storage driver"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("storage_driver must be %q or %q", StorageSQLite, StoragePostgres))
+	}
+	if c.HistoryLimit < 0 {
+		errs = append(errs, errors.New("history_limit must not be negative"))
+	}
+	if c.RequestTimeout < 0 {
+		errs = append(errs, errors.New("request_timeout must not be negative"))
+	}
+	if c.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("max_body_bytes must be positive"))
+	}
+	if c.MaxLogs <= 0 {
+		errs = append(errs, errors.New("max_logs must be positive"))
+	}
+	if c.LogRateLimit < 0 || (c.LogRateLimit > 0 && c.LogRateBurst < 1) {
+		errs = append(errs, errors.New("log_rate_limit must not be negative and needs a positive log_rate_burst"))
+	}
+	if c.LogRetention < 0 {
+		errs = append(errs, errors.New("log_retention must not be negative"))
+	}
+	if c.LogDedupWindow < 0 {
+		errs = append(errs, errors.New("log_dedup_window must not be negative"))
+	}
+	for i, hook := range c.Webhooks {
+		if u, err := url.Parse(hook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("webhooks[%d]: %q is not an http(s) URL", i, hook.URL))
+		}
+	}
+	if c.DNSCacheTTL < 0 {
+		errs = append(errs, errors.New("dns_cache_ttl must not be negative"))
+	}
+	for _, entry := range c.HighRiskIPs {
+		if !validIPEntry(entry) {
+			errs = append(errs, fmt.Errorf("high_risk_ips: %q is not an IP or CIDR", entry))
+		}
+	}
+	for name := range c.Variables {
+		if !variableNameRE.MatchString(name) {
+			errs = append(errs, fmt.Errorf("variables: %q is not a valid name", name))
+		}
+	}
+	if c.IdempotencyTTL < 0 {
+		errs = append(errs, errors.New("idempotency_ttl must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Apply copies the runtime settings onto s before it serves. Reload handles
+// later changes.
+func (c Config) Apply(s *CentralServer) {
+	s.config = c
+	s.AuthToken = c.AuthToken
+	s.AuthTokens = c.AuthTokens
+	s.AuthReads = c.AuthReads
+	s.TLSCertFile = c.TLSCertFile
+	s.TLSKeyFile = c.TLSKeyFile
+	s.GRPCReadOnly = c.AdminAddr != ""
+	s.MaxLogs = c.MaxLogs
+	s.LogRetention = time.Duration(c.LogRetention)
+	s.LogDedupWindow = time.Duration(c.LogDedupWindow)
+	s.CORSOrigins = c.CORSOrigins
+	s.Variables = c.Variables
+	s.Classify = severityClassifier(c.HighRiskDomains, c.HighRiskIPs)
+	s.MaxBodyBytes = c.MaxBodyBytes
+	s.RequestTimeout = time.Duration(c.RequestTimeout)
+	s.HistoryLimit = c.HistoryLimit
+	s.logLimiter.SetLimit(c.LogRateLimit, c.LogRateBurst)
+	s.idempotent.SetTTL(time.Duration(c.IdempotencyTTL))
+	s.webhooks = nil
+	if len(c.Webhooks) > 0 {
+		s.webhooks = newWebhookDispatcher(c.Webhooks)
+	}
+	s.DNS = nil
+	if c.DNSCacheTTL > 0 {
+		s.DNS = NewDNSCache(net.DefaultResolver, time.Duration(c.DNSCacheTTL))
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, `{"addr": ":7000", "auth_token": "from-file", "log_retention": "72h", "cors_origins": ["https://a.example"]}`)
+	t.Setenv("AUTH_TOKEN", "from-env")
+	t.Setenv("MAX_LOGS", "500")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Addr != ":7000" || time.Duration(cfg.LogRetention) != 72*time.Hour || len(cfg.CORSOrigins) != 1 {
+		t.Errorf("file settings not applied: %+v", cfg)
+	}
+	if cfg.AuthToken != "from-env" || cfg.MaxLogs != 500 {
+		t.Errorf("env did not override the file: auth_token %q, max_logs %d", cfg.AuthToken, cfg.MaxLogs)
+	}
+	if cfg.GRPCAddr != ":9090" || cfg.StorageDriver != StorageSQLite || cfg.HistoryLimit != defaultHistoryLimit {
+		t.Errorf("defaults not kept: %+v", cfg)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		env  map[string]string
+		want []string
+	}{
+		{"missing file", filepath.Join(t.TempDir(), "nope.json"), nil, []string{"no such file"}},
+		{"malformed file", writeConfig(t, `{"addr": `), nil, []string{"parsing"}},
+		{"bad duration", writeConfig(t, `{"log_retention": "soon"}`), nil, []string{"parsing"}},
+		{"bad env", "", map[string]string{"MAX_LOGS": "many"}, []string{"MAX_LOGS"}},
+		{"every problem", writeConfig(t, `{"max_logs": -1, "storage_driver": "mysql", "webhooks": [{"url": "ftp://x"}]}`), nil,
+			[]string{"max_logs", "storage_driver", "webhooks[0]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, v := range tt.env {
+				t.Setenv(name, v)
+			}
+			_, err := LoadConfig(tt.path)
+			if err == nil {
+				t.Fatal("LoadConfig succeeded")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %s", err, want)
+				}
+			}
+		})
+	}
+}
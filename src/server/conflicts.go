@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	ConflictDomain = "domain"
+	ConflictIP     = "ip"
+	ConflictPort   = "port"
+)
+
+// Conflict is an allow-list entry that overlaps a block-list entry. The block
+// always wins, so the overlapping part of the allow entry has no effect.
+type Conflict struct {
+	Kind    string `json:"kind"`
+	Allowed string `json:"allowed"`
+	Blocked string `json:"blocked"`
+}
+
+// Conflicts reports every pair of allow and block entries that overlap,
+// accounting for CIDR containment, wildcard domains and port ranges.
+func (r FirewallRule) Conflicts() []Conflict {
+	var conflicts []Conflict
+	for _, allowed := range r.AllowedDomains {
+		for _, blocked := range r.BlockedDomains {
+			if domainsOverlap(allowed, blocked) {
+				conflicts = append(conflicts, Conflict{Kind: ConflictDomain, Allowed: allowed, Blocked: blocked})
+			}
+		}
+	}
+	for _, allowed := range r.AllowedIPs {
+		for _, blocked := range r.BlockedIPs {
+			if ipEntriesOverlap(allowed, blocked) {
+				conflicts = append(conflicts, Conflict{Kind: ConflictIP, Allowed: allowed, Blocked: blocked})
+			}
+		}
+	}
+	for _, blocked := range r.BlockedPorts {
+		for _, allowed := range r.AllowedPorts {
+			if allowed.Contains(blocked) {
+				conflicts = append(conflicts, Conflict{Kind: ConflictPort, Allowed: allowed.String(), Blocked: strconv.Itoa(blocked)})
+			}
+		}
+	}
+	return conflicts
+}
+
+// domainsOverlap reports whether some host matches both entries.
+func domainsOverlap(a, b string) bool {
+	a, b = normalizeDomain(a), normalizeDomain(b)
+	aSuffix, aWild := strings.CutPrefix(a, "*.")
+	bSuffix, bWild := strings.CutPrefix(b, "*.")
+	switch {
+	case aWild && bWild:
+		return aSuffix == bSuffix || strings.HasSuffix(aSuffix, "."+bSuffix) || strings.HasSuffix(bSuffix, "."+aSuffix)
+	case aWild:
+		return matchDomain(a, b)
+	case bWild:
+		return matchDomain(b, a)
+	default:
+		return a == b
+	}
+}
+
+// ipEntryNetwork treats a bare address as a single-host network.
+func ipEntryNetwork(entry string) *net.IPNet {
+	if network, err := parseCIDR(entry); err == nil {
+		return network
+	}
+	ip := parseIP(entry)
+	if ip == nil {
+		return nil
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return &net.IPNet{IP: v4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+// ipEntriesOverlap reports whether two address or CIDR entries share an
+// address. Aligned networks overlap exactly when one contains the other's base.
+func ipEntriesOverlap(a, b string) bool {
+	na, nb := ipEntryNetwork(a), ipEntryNetwork(b)
+	if na == nil || nb == nil {
+		return false
+	}
+	return na.Contains(nb.IP) || nb.Contains(na.IP)
+}
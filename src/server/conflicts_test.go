@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestConflicts(t *testing.T) {
+	tests := []struct {
+		name string
+		rule FirewallRule
+		want []Conflict
+	}{
+		{"cidr inside cidr", FirewallRule{AllowedIPs: []string{"10.0.0.0/8"}, BlockedIPs: []string{"10.1.0.0/16"}},
+			[]Conflict{{ConflictIP, "10.0.0.0/8", "10.1.0.0/16"}}},
+		{"cidr around cidr", FirewallRule{AllowedIPs: []string{"10.1.0.0/16"}, BlockedIPs: []string{"10.0.0.0/8"}},
+			[]Conflict{{ConflictIP, "10.1.0.0/16", "10.0.0.0/8"}}},
+		{"address in cidr", FirewallRule{AllowedIPs: []string{"10.0.0.0/8"}, BlockedIPs: []string{"10.0.0.5"}},
+			[]Conflict{{ConflictIP, "10.0.0.0/8", "10.0.0.5"}}},
+		{"disjoint cidrs", FirewallRule{AllowedIPs: []string{"10.0.0.0/8"}, BlockedIPs: []string{"11.0.0.0/8", "::1"}}, nil},
+		{"wildcard and host", FirewallRule{AllowedDomains: []string{"*.example.com"}, BlockedDomains: []string{"ads.example.com", "example.com"}},
+			[]Conflict{{ConflictDomain, "*.example.com", "ads.example.com"}}},
+		{"nested wildcards", FirewallRule{AllowedDomains: []string{"*.cdn.example.com"}, BlockedDomains: []string{"*.example.com"}},
+			[]Conflict{{ConflictDomain, "*.cdn.example.com", "*.example.com"}}},
+		{"port in range", FirewallRule{AllowedPorts: []PortRange{{8000, 8100}, {443, 443}}, BlockedPorts: []int{8080, 22}},
+			[]Conflict{{ConflictPort, "8000-8100", "8080"}}},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.Conflicts(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Conflicts() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSetRuleWarnsOfConflicts(t *testing.T) {
+	h := newTestServer(t).Handler()
+	rec := call(t, h, "POST", "/v1/rule", FirewallRule{AppName: "curl", AllowedIPs: []string{"10.0.0.0/8"}, BlockedIPs: []string{"10.1.0.0/16"}})
+	expect(t, rec, http.StatusCreated)
+	// Not storedRule, whose promoted UnmarshalJSON would drop Warnings.
+	var stored struct {
+		Warnings []Conflict `json:"warnings"`
+	}
+	decode(t, rec, &stored)
+	if len(stored.Warnings) != 1 || stored.Warnings[0].Blocked != "10.1.0.0/16" {
+		t.Errorf("warnings = %+v, want the 10.1.0.0/16 overlap", stored.Warnings)
+	}
+}
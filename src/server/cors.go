@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+)
+
+const (
+	corsAllowMethods  = "GET, POST, PATCH, DELETE, OPTIONS"
+	corsAllowHeaders  = "Authorization, Content-Type, If-None-Match, Idempotency-Key"
+	corsExposeHeaders = "ETag, Deprecation, Link, Idempotent-Replayed"
+)
+
+func (s *CentralServer) originAllowed(origin string) bool {
+	s.liveMu.RLock()
+	defer s.liveMu.RUnlock()
+	return slices.Contains(s.CORSOrigins, "*") || slices.Contains(s.CORSOrigins, origin)
+}
+
+// cors answers preflight requests and tags responses for origins listed in
+// CORSOrigins. Requests from any other origin are refused; requests without
+// an Origin header (agents, curl) pass through untouched.
+func (s *CentralServer) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		if !s.originAllowed(origin) {
+			writeError(w, http.StatusForbidden, CodeOriginNotAllowed, "Origin not allowed")
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Expose-Headers", corsExposeHeaders)
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	s := newTestServer(t)
+	s.CORSOrigins = []string{"https://console.example"}
+	h := s.Handler()
+
+	tests := []struct {
+		name       string
+		method     string
+		origin     string
+		preflight  bool
+		want       int
+		wantOrigin string
+	}{
+		{"no origin", "GET", "", false, http.StatusOK, ""},
+		{"allowed origin", "GET", "https://console.example", false, http.StatusOK, "https://console.example"},
+		{"refused origin", "GET", "https://evil.example", false, http.StatusForbidden, ""},
+		{"preflight", "OPTIONS", "https://console.example", true, http.StatusNoContent, "https://console.example"},
+		{"refused preflight", "OPTIONS", "https://evil.example", true, http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest(t, tt.method, "/v1/rules", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", "POST")
+			}
+			rec := serve(h, req)
+			expect(t, rec, tt.want)
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Methods"); tt.preflight && tt.want == http.StatusNoContent && got != corsAllowMethods {
+				t.Errorf("Access-Control-Allow-Methods = %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type countResponse struct {
+	Count int `json:"count"`
+}
+
+func (s *CentralServer) HandleCountRules(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	rules, err := s.store.List(r.Context())
+	s.mu.RUnlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+
+	json.NewEncoder(w).Encode(countResponse{Count: len(rules)})
+}
+
+// HandleCountLogs counts stored logs under the same filters as GET /logs.
+func (s *CentralServer) HandleCountLogs(w http.ResponseWriter, r *http.Request) {
+	f, err := parseLogFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Invalid log filter: "+err.Error())
+		return
+	}
+
+	json.NewEncoder(w).Encode(countResponse{Count: s.countLogs(f)})
+}
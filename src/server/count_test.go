@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCountLogs(t *testing.T) {
+	s := newTestServer(t)
+	h := s.Handler()
+	putRule(t, h, FirewallRule{AppName: "curl", Enabled: true})
+	putRule(t, h, FirewallRule{AppName: "wget", Enabled: true})
+	for _, entry := range []NetworkLog{
+		{AppName: "curl", RemoteIP: "10.0.0.1", Port: 443, Action: ActionAllowed},
+		{AppName: "curl", RemoteIP: "10.0.0.2", Port: 443, Action: ActionBlocked},
+		{AppName: "curl", RemoteIP: "10.0.0.3", Port: 443, Action: ActionBlocked},
+		{AppName: "wget", RemoteIP: "10.0.0.1", Port: 80, Action: ActionBlocked},
+	} {
+		expect(t, call(t, h, "POST", "/v1/logs", entry), http.StatusCreated)
+	}
+
+	for path, want := range map[string]int{
+		"/v1/rules/count":                             2,
+		"/v1/logs/count":                              4,
+		"/v1/logs/count?app_name=curl":                3,
+		"/v1/logs/count?action=blocked":               3,
+		"/v1/logs/count?app_name=curl&action=blocked": 2,
+		"/v1/logs/count?app_name=nope":                0,
+		"/v1/logs/count?since=1h":                     4,
+	} {
+		rec := call(t, h, "GET", path, nil)
+		expect(t, rec, http.StatusOK)
+		var got countResponse
+		decode(t, rec, &got)
+		if got.Count != want {
+			t.Errorf("GET %s: count = %d, want %d", path, got.Count, want)
+		}
+	}
+
+	expect(t, call(t, h, "GET", "/v1/logs/count?since=soon", nil), http.StatusBadRequest)
+}
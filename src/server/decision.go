@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+type decisionRequest struct {
+	AppName  string `json:"app_name"`
+	Domain   string `json:"domain"`
+	IP       string `json:"ip"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+}
+
+type decisionResponse struct {
+	Decision    Decision `json:"decision"`
+	Reason      string   `json:"reason"`
+	RuleVersion int      `json:"rule_version"`
+}
+
+// Decide evaluates a connection against the app's effective rule, or the
+// default rule when the app has none. With neither it returns NoMatch and
+// leaves the call to the agent.
+func (s *CentralServer) Decide(ctx context.Context, req decisionRequest, ip net.IP) (decisionResponse, error) {
+	rule, fellBack, err := s.decisionRule(ctx, req.AppName)
+	if errors.Is(err, ErrRuleNotFound) {
+		observeEvaluation("", NoMatch, true)
+		return decisionResponse{Decision: NoMatch, Reason: "no rule for app and no default rule"}, nil
+	}
+	if err != nil {
+		return decisionResponse{}, err
+	}
+
+	decision, matched := rule.Explain(s.evalEnv(), req.Domain, ip, req.Port, req.Protocol)
+	observeEvaluation(rule.AppName, decision, fellBack)
+	resp := decisionResponse{Decision: decision, RuleVersion: rule.Version}
+	switch {
+	case matched != "":
+		resp.Reason = fmt.Sprintf("matched %s in rule %q", matched, rule.AppName)
+	case decision != NoMatch:
+		resp.Reason = fmt.Sprintf("default action of rule %q", rule.AppName)
+	default:
+		resp.Reason = fmt.Sprintf("nothing in rule %q matched", rule.AppName)
+	}
+	return resp, nil
+}
+
+// decisionRule returns the effective rule Decide evaluates for appName and
+// whether it is the default rule. The read lock is held only while the rule
+// and its templates are read; the rule returned is a copy, so matching,
+// which may consult the DNS cache, runs without it.
+func (s *CentralServer) decisionRule(ctx context.Context, appName string) (FirewallRule, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rule, err := s.enabledRuleLocked(ctx, appName)
+	fellBack := false
+	if errors.Is(err, ErrRuleNotFound) && appName != DefaultRuleName {
+		rule, err = s.enabledRuleLocked(ctx, DefaultRuleName)
+		fellBack = true
+	}
+	if err != nil {
+		return FirewallRule{}, false, err
+	}
+	rule, err = s.effectiveLocked(ctx, rule)
+	return rule, fellBack, err
+}
+
+func (s *CentralServer) HandleDecision(w http.ResponseWriter, r *http.Request) {
+	var req decisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if req.AppName == "" {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "app_name is required")
+		return
+	}
+	var ip net.IP
+	if req.IP != "" {
+		if ip = parseIP(req.IP); ip == nil {
+			writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Invalid ip")
+			return
+		}
+	}
+
+	resp, err := s.Decide(r.Context(), req, ip)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
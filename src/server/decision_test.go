@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDecision(t *testing.T) {
+	h := newTestServer(t).Handler()
+	putRule(t, h, FirewallRule{
+		AppName:        "curl",
+		AllowedDomains: []string{"example.com"},
+		BlockedDomains: []string{"ads.example"},
+		BlockedIPs:     []string{"203.0.113.0/24"},
+		Enabled:        true,
+	})
+
+	tests := []struct {
+		name string
+		req  decisionRequest
+		want Decision
+		rule string
+	}{
+		{"allowed domain", decisionRequest{AppName: "curl", Domain: "example.com", Port: 443, Protocol: "tcp"}, Allow, "curl"},
+		{"blocked domain", decisionRequest{AppName: "curl", Domain: "ads.example", Port: 443, Protocol: "tcp"}, Block, "curl"},
+		{"blocked ip", decisionRequest{AppName: "curl", IP: "203.0.113.9", Port: 443, Protocol: "tcp"}, Block, "curl"},
+		{"no rule", decisionRequest{AppName: "wget", Domain: "example.com", Port: 443, Protocol: "tcp"}, NoMatch, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := call(t, h, "POST", "/v1/decision", tt.req)
+			expect(t, rec, http.StatusOK)
+			var got decisionResponse
+			decode(t, rec, &got)
+			if got.Decision != tt.want || !strings.Contains(got.Reason, tt.rule) {
+				t.Errorf("decision = %+v, want %s from rule %q", got, tt.want, tt.rule)
+			}
+		})
+	}
+
+	expect(t, call(t, h, "POST", "/v1/decision", decisionRequest{Domain: "example.com"}), http.StatusBadRequest)
+	expect(t, call(t, h, "POST", "/v1/decision", decisionRequest{AppName: "curl", IP: "10.0.0.300"}), http.StatusBadRequest)
+}
+
+func TestDecisionFallsBackToDefaultRule(t *testing.T) {
+	h := newTestServer(t).Handler()
+	putRule(t, h, FirewallRule{AppName: DefaultRuleName, AllowedDomains: []string{"updates.example"}, Enabled: true})
+
+	tests := []struct {
+		domain string
+		want   Decision
+	}{
+		{"updates.example", Allow},
+		{"example.com", Block},
+	}
+	for _, tt := range tests {
+		rec := call(t, h, "POST", "/v1/decision", decisionRequest{AppName: "wget", Domain: tt.domain, Port: 443, Protocol: "tcp"})
+		expect(t, rec, http.StatusOK)
+		var got decisionResponse
+		decode(t, rec, &got)
+		if got.Decision != tt.want || !strings.Contains(got.Reason, `"*"`) || got.RuleVersion != 1 {
+			t.Errorf("%s: decision = %+v, want %s from the default rule", tt.domain, got, tt.want)
+		}
+	}
+}
+
+func BenchmarkDecide(b *testing.B) {
+	s := newTestServer(b)
+	putRule(b, s.Handler(), FirewallRule{
+		AppName:        "curl",
+		AllowedDomains: []string{"example.com", "*.example.org"},
+		BlockedIPs:     []string{"203.0.113.0/24"},
+		Enabled:        true,
+	})
+	req := decisionRequest{AppName: "curl", Domain: "api.example.org", Port: 443, Protocol: "tcp"}
+	ctx := context.Background()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := s.Decide(ctx, req, nil); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
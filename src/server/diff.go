@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// diffRequest names two rule sets in the export format, or as bare arrays.
+// A missing To compares From against the live rules.
+type diffRequest struct {
+	From json.RawMessage `json:"from"`
+	To   json.RawMessage `json:"to,omitempty"`
+}
+
+// ruleDiff describes how to turn From into To.
+type ruleDiff struct {
+	Added   []FirewallRule `json:"added"`
+	Removed []FirewallRule `json:"removed"`
+	Changed []ruleChange   `json:"changed"`
+}
+
+type ruleChange struct {
+	AppName string        `json:"app_name"`
+	Fields  []fieldChange `json:"fields"`
+}
+
+// fieldChange is one differing field of a rule. List fields report the
+// entries gained and lost; anything else reports both values.
+type fieldChange struct {
+	Field   string `json:"field"`
+	Added   []any  `json:"added,omitempty"`
+	Removed []any  `json:"removed,omitempty"`
+	Before  any    `json:"before,omitempty"`
+	After   any    `json:"after,omitempty"`
+}
+
+// diffIgnored are bookkeeping fields that differ between any two stores.
+var diffIgnored = map[string]bool{"version": true, "updated_at": true}
+
+// ruleFieldValues flattens a rule into its JSON fields.
+func ruleFieldValues(rule FirewallRule) map[string]any {
+	data, _ := json.Marshal(rule)
+	var fields map[string]any
+	json.Unmarshal(data, &fields)
+	return fields
+}
+
+// listDifference returns the entries of a missing from b, compared by their
+// JSON encoding.
+func listDifference(a, b []any) []any {
+	have := make(map[string]bool, len(b))
+	for _, v := range b {
+		key, _ := json.Marshal(v)
+		have[string(key)] = true
+	}
+	var out []any
+	for _, v := range a {
+		if key, _ := json.Marshal(v); !have[string(key)] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// diffRuleFields lists the fields that differ between two versions of a
+// rule, sorted by name. An absent or null list equals an empty one.
+func diffRuleFields(from, to FirewallRule) []fieldChange {
+	before, after := ruleFieldValues(from), ruleFieldValues(to)
+	names := map[string]bool{}
+	for name := range before {
+		names[name] = true
+	}
+	for name := range after {
+		names[name] = true
+	}
+
+	var changes []fieldChange
+	for name := range names {
+		if diffIgnored[name] {
+			continue
+		}
+		b, a := before[name], after[name]
+		bl, bIsList := b.([]any)
+		al, aIsList := a.([]any)
+		if (bIsList || b == nil) && (aIsList || a == nil) && (bIsList || aIsList) {
+			added, removed := listDifference(al, bl), listDifference(bl, al)
+			if len(added) > 0 || len(removed) > 0 {
+				changes = append(changes, fieldChange{Field: name, Added: added, Removed: removed})
+			}
+			continue
+		}
+		if !reflect.DeepEqual(b, a) {
+			changes = append(changes, fieldChange{Field: name, Before: b, After: a})
+		}
+	}
+	slices.SortFunc(changes, func(a, b fieldChange) int { return strings.Compare(a.Field, b.Field) })
+	return changes
+}
+
+func indexRules(rules []FirewallRule) (map[string]FirewallRule, error) {
+	byName := make(map[string]FirewallRule, len(rules))
+	for _, rule := range rules {
+		if _, dup := byName[rule.AppName]; dup {
+			return nil, fmt.Errorf("duplicate app_name %q", rule.AppName)
+		}
+		byName[rule.AppName] = rule
+	}
+	return byName, nil
+}
+
+// diffRules compares two rule sets by app name.
+func diffRules(from, to []FirewallRule) (ruleDiff, error) {
+	diff := ruleDiff{Added: []FirewallRule{}, Removed: []FirewallRule{}, Changed: []ruleChange{}}
+	before, err := indexRules(from)
+	if err != nil {
+		return diff, fmt.Errorf("from: %w", err)
+	}
+	after, err := indexRules(to)
+	if err != nil {
+		return diff, fmt.Errorf("to: %w", err)
+	}
+
+	for _, rule := range to {
+		old, ok := before[rule.AppName]
+		if !ok {
+			diff.Added = append(diff.Added, rule)
+		} else if fields := diffRuleFields(old, rule); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, ruleChange{AppName: rule.AppName, Fields: fields})
+		}
+	}
+	for _, rule := range from {
+		if _, ok := after[rule.AppName]; !ok {
+			diff.Removed = append(diff.Removed, rule)
+		}
+	}
+	byName := func(a, b FirewallRule) int { return strings.Compare(a.AppName, b.AppName) }
+	slices.SortFunc(diff.Added, byName)
+	slices.SortFunc(diff.Removed, byName)
+	slices.SortFunc(diff.Changed, func(a, b ruleChange) int { return strings.Compare(a.AppName, b.AppName) })
+	return diff, nil
+}
+
+func (s *CentralServer) HandleDiffRules(w http.ResponseWriter, r *http.Request) {
+	var req diffRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if len(req.From) == 0 || string(req.From) == "null" {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "from is required")
+		return
+	}
+	from, err := decodeRuleBatch(bytes.NewReader(req.From))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "from: "+err.Error())
+		return
+	}
+	var to []FirewallRule
+	if len(req.To) > 0 && string(req.To) != "null" {
+		if to, err = decodeRuleBatch(bytes.NewReader(req.To)); err != nil {
+			writeError(w, http.StatusBadRequest, CodeInvalidRequest, "to: "+err.Error())
+			return
+		}
+	} else {
+		s.mu.RLock()
+		to, err = s.store.List(r.Context())
+		s.mu.RUnlock()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, CodeInternal, err.Error())
+			return
+		}
+	}
+
+	diff, err := diffRules(from, to)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	json.NewEncoder(w).Encode(diff)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDiffRules(t *testing.T) {
+	h := newTestServer(t).Handler()
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"example.com", "example.org"}, Enabled: true})
+	putRule(t, h, FirewallRule{AppName: "git", Enabled: true})
+
+	// from is the saved set: curl lacked example.org, wget has since gone
+	// and git was added.
+	from := `[
+		{"app_name": "curl", "allowed_domains": ["example.com"], "default_action": "block", "version": 4},
+		{"app_name": "wget", "allowed_domains": ["example.com"]}
+	]`
+	rec := call(t, h, "POST", "/v1/rules/diff", `{"from": `+from+`}`)
+	expect(t, rec, http.StatusOK)
+	var diff ruleDiff
+	decode(t, rec, &diff)
+
+	if len(diff.Added) != 1 || diff.Added[0].AppName != "git" {
+		t.Errorf("added = %+v, want git", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].AppName != "wget" {
+		t.Errorf("removed = %+v, want wget", diff.Removed)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].AppName != "curl" {
+		t.Fatalf("changed = %+v, want curl", diff.Changed)
+	}
+	fields := diff.Changed[0].Fields
+	if len(fields) != 1 || fields[0].Field != "allowed_domains" ||
+		len(fields[0].Added) != 1 || fields[0].Added[0] != "example.org" || len(fields[0].Removed) != 0 {
+		t.Errorf("curl changed %+v, want only example.org gained", fields)
+	}
+
+	rec = call(t, h, "POST", "/v1/rules/diff", `{"from": [{"app_name": "curl"}, {"app_name": "curl"}]}`)
+	expect(t, rec, http.StatusBadRequest)
+	expect(t, call(t, h, "POST", "/v1/rules/diff", `{}`), http.StatusBadRequest)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const (
+	defaultDryRunLogs = 500
+	maxDryRunLogs     = 10000
+)
+
+// dryRunChange is a replayed log whose decision the proposed rule changes.
+type dryRunChange struct {
+	Log     NetworkLog `json:"log"`
+	Before  Decision   `json:"before"`
+	After   Decision   `json:"after"`
+	Matched string     `json:"matched,omitempty"`
+}
+
+type dryRunResult struct {
+	Evaluated    int            `json:"evaluated"`
+	NewlyAllowed []dryRunChange `json:"newly_allowed"`
+	NewlyBlocked []dryRunChange `json:"newly_blocked"`
+}
+
+// parseDryRunLogs reads the optional logs query parameter.
+func parseDryRunLogs(v string) (int, error) {
+	if v == "" {
+		return defaultDryRunLogs, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 || n > maxDryRunLogs {
+		return 0, fmt.Errorf("logs must be between 1 and %d", maxDryRunLogs)
+	}
+	return n, nil
+}
+
+// dryRunRule replays the newest n logs for the proposed rule's app through
+// both the rule in force now and the proposal, returning the traffic whose
+// outcome would flip. The proposal is prepared as SetRule would store it.
+// Nothing is stored.
+func (s *CentralServer) dryRunRule(ctx context.Context, proposed FirewallRule, n int) (dryRunResult, error) {
+	if err := s.validateRule(proposed); err != nil {
+		return dryRunResult{}, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+	}
+	current, _, err := s.EffectiveRule(ctx, proposed.AppName)
+	if err != nil && !errors.Is(err, ErrRuleNotFound) {
+		return dryRunResult{}, err
+	}
+	s.mu.RLock()
+	// Normalise the proposal as storing it would, so an omitted
+	// DefaultAction is inherited from the stored rule.
+	stored, err := s.store.Get(ctx, proposed.AppName)
+	if errors.Is(err, ErrRuleNotFound) {
+		stored, err = FirewallRule{}, nil
+	}
+	if err == nil {
+		proposed.prepareSuccessor(stored)
+		get := func(appName string) (FirewallRule, error) { return s.store.Get(ctx, appName) }
+		err = checkTemplates(proposed, get)
+	}
+	if err == nil {
+		proposed, err = s.effectiveLocked(ctx, proposed)
+	}
+	s.mu.RUnlock()
+	if err != nil {
+		return dryRunResult{}, err
+	}
+
+	logs := s.filteredLogs(logFilter{AppName: proposed.AppName})
+	logs = logs[:min(n, len(logs))]
+
+	result := dryRunResult{Evaluated: len(logs), NewlyAllowed: []dryRunChange{}, NewlyBlocked: []dryRunChange{}}
+	env := s.evalEnv()
+	for _, entry := range logs {
+		ip := parseIP(entry.RemoteIP)
+		before, _ := current.Explain(env, entry.RemoteDomain, ip, entry.Port, entry.Protocol)
+		after, matched := proposed.Explain(env, entry.RemoteDomain, ip, entry.Port, entry.Protocol)
+		change := dryRunChange{Log: entry, Before: before, After: after, Matched: matched}
+		switch {
+		case before != Allow && after == Allow:
+			result.NewlyAllowed = append(result.NewlyAllowed, change)
+		case before == Allow && after != Allow:
+			result.NewlyBlocked = append(result.NewlyBlocked, change)
+		}
+	}
+	return result, nil
+}
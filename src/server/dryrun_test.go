@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDryRunStoresNothing(t *testing.T) {
+	h := newTestServer(t).Handler()
+	before := putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"example.com"}, Enabled: true})
+	for _, domain := range []string{"example.com", "example.org", "example.org"} {
+		entry := NetworkLog{AppName: "curl", RemoteDomain: domain, Port: 443, Protocol: "tcp", Action: ActionAllowed}
+		expect(t, call(t, h, "POST", "/v1/logs", entry), http.StatusCreated)
+	}
+
+	proposal := FirewallRule{AppName: "curl", AllowedDomains: []string{"example.org"}, Enabled: true}
+	rec := call(t, h, "POST", "/v1/rule?dry_run=true", proposal)
+	expect(t, rec, http.StatusOK)
+	var result dryRunResult
+	decode(t, rec, &result)
+	if result.Evaluated != 3 || len(result.NewlyAllowed) != 2 || len(result.NewlyBlocked) != 1 {
+		t.Errorf("result = %d evaluated, %d newly allowed, %d newly blocked; want 3, 2, 1",
+			result.Evaluated, len(result.NewlyAllowed), len(result.NewlyBlocked))
+	}
+
+	var after FirewallRule
+	decode(t, call(t, h, "GET", "/v1/rule/curl", nil), &after)
+	if after.Version != before.Version || after.AllowedDomains[0] != "example.com" {
+		t.Errorf("dry run changed the rule to %+v", after)
+	}
+	var history []FirewallRule
+	decode(t, call(t, h, "GET", "/v1/rule/curl/history", nil), &history)
+	var audit []AuditEntry
+	decode(t, call(t, h, "GET", "/v1/audit", nil), &audit)
+	if len(history) != 1 || len(audit) != 1 {
+		t.Errorf("dry run left %d revisions and %d audit entries, want 1 of each", len(history), len(audit))
+	}
+
+	rec = call(t, h, "POST", "/v1/rule?dry_run=true", FirewallRule{AppName: "wget", Enabled: true})
+	expect(t, rec, http.StatusOK)
+	expect(t, call(t, h, "GET", "/v1/rule/wget", nil), http.StatusNotFound)
+}
+
+func TestDryRunInheritsDefaultAction(t *testing.T) {
+	h := newTestServer(t).Handler()
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"example.com"}, DefaultAction: Allow, Enabled: true})
+	entry := NetworkLog{AppName: "curl", RemoteDomain: "other.org", Port: 443, Protocol: "tcp", Action: ActionAllowed}
+	expect(t, call(t, h, "POST", "/v1/logs", entry), http.StatusCreated)
+
+	// Leaving default_action out keeps the stored one, so nothing flips.
+	proposal := `{"app_name": "curl", "allowed_domains": ["example.com"]}`
+	rec := call(t, h, "POST", "/v1/rule?dry_run=true", proposal)
+	expect(t, rec, http.StatusOK)
+	var result dryRunResult
+	decode(t, rec, &result)
+	if result.Evaluated != 1 || len(result.NewlyAllowed) != 0 || len(result.NewlyBlocked) != 0 {
+		t.Errorf("result = %d evaluated, %d newly allowed, %d newly blocked; want 1, 0, 0",
+			result.Evaluated, len(result.NewlyAllowed), len(result.NewlyBlocked))
+	}
+
+	stored := putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"example.com"}, Enabled: true})
+	if stored.DefaultAction != Allow {
+		t.Errorf("stored default_action = %q, want %q inherited as in the dry run", stored.DefaultAction, Allow)
+	}
+}
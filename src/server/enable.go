@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// SetRuleEnabled switches appName's rule on or off, keeping its lists and
+// history. A rule already in the requested state is returned unchanged
+// rather than bumped to a new version. In read-only mode every toggle is
+// refused, including those that would change nothing.
+func (s *CentralServer) SetRuleEnabled(ctx context.Context, appName string, enabled bool) (FirewallRule, error) {
+	s.mu.Lock()
+	current, err := s.store.Get(ctx, appName)
+	if err == nil {
+		err = s.checkWritableLocked()
+	}
+	if err != nil || current.Enabled == enabled {
+		s.mu.Unlock()
+		return current, err
+	}
+	rule := current
+	rule.Enabled = enabled
+	err = s.storeRuleLocked(ctx, &rule, current)
+	s.mu.Unlock()
+	if err != nil {
+		return current, err
+	}
+	return rule, nil
+}
+
+// handleSetEnabled serves POST /rule/{app_name}/enable and /disable.
+func (s *CentralServer) handleSetEnabled(enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rule, err := s.SetRuleEnabled(r.Context(), mux.Vars(r)["app_name"], enabled)
+		if err != nil {
+			writeRuleError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(rule)
+	}
+}
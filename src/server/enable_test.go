@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDisableRule(t *testing.T) {
+	h := newTestServer(t).Handler()
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"example.com"}, Enabled: true})
+	req := decisionRequest{AppName: "curl", Domain: "example.com", Port: 443, Protocol: "tcp"}
+	decide := func() Decision {
+		t.Helper()
+		rec := call(t, h, "POST", "/v1/decision", req)
+		expect(t, rec, http.StatusOK)
+		var got decisionResponse
+		decode(t, rec, &got)
+		return got.Decision
+	}
+	if got := decide(); got != Allow {
+		t.Fatalf("enabled rule decided %s, want %s", got, Allow)
+	}
+
+	rec := call(t, h, "POST", "/v1/rule/curl/disable", nil)
+	expect(t, rec, http.StatusOK)
+	var rule FirewallRule
+	decode(t, rec, &rule)
+	if rule.Enabled || rule.Version != 2 || rule.AllowedDomains[0] != "example.com" {
+		t.Errorf("disabled rule = %+v, want version 2 with its lists kept", rule)
+	}
+	if got := decide(); got != NoMatch {
+		t.Errorf("disabled rule decided %s, want %s", got, NoMatch)
+	}
+	expect(t, call(t, h, "GET", "/v1/rule/curl", nil), http.StatusNotFound)
+	expect(t, call(t, h, "POST", "/v1/rule/curl/disable", nil), http.StatusOK)
+
+	expect(t, call(t, h, "POST", "/v1/rule/curl/enable", nil), http.StatusOK)
+	if got := decide(); got != Allow {
+		t.Errorf("re-enabled rule decided %s, want %s", got, Allow)
+	}
+	rec = call(t, h, "GET", "/v1/rule/curl", nil)
+	expect(t, rec, http.StatusOK)
+	decode(t, rec, &rule)
+	if !rule.Enabled || rule.Version != 3 || rule.AllowedDomains[0] != "example.com" {
+		t.Errorf("re-enabled rule = %+v, want version 3 with its lists", rule)
+	}
+
+	expect(t, call(t, h, "POST", "/v1/rule/wget/enable", nil), http.StatusNotFound)
+}
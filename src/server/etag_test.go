@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestIfNoneMatch(t *testing.T) {
+	h := newTestServer(t).Handler()
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"example.com"}, Enabled: true})
+
+	rec := call(t, h, "GET", "/v1/rule/curl", nil)
+	expect(t, rec, http.StatusOK)
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		want        int
+	}{
+		{"same tag", etag, http.StatusNotModified},
+		{"weak form", "W/" + etag, http.StatusNotModified},
+		{"in a list", `"stale", ` + etag, http.StatusNotModified},
+		{"wildcard", "*", http.StatusNotModified},
+		{"other tag", `"stale"`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := newRequest(t, "GET", "/v1/rule/curl", nil)
+		req.Header.Set("If-None-Match", tt.ifNoneMatch)
+		rec := serve(h, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+		if tt.want == http.StatusNotModified && rec.Body.Len() != 0 {
+			t.Errorf("%s: 304 carried a body", tt.name)
+		}
+	}
+
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"example.org"}, Enabled: true})
+	req := newRequest(t, "GET", "/v1/rule/curl", nil)
+	req.Header.Set("If-None-Match", etag)
+	if rec := serve(h, req); rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Errorf("after an update: status %d, ETag %s; want 200 with a new tag", rec.Code, rec.Header().Get("ETag"))
+	}
+}
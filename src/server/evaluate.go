@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type evaluateRequest struct {
+	Domain   string `json:"domain"`
+	IP       string `json:"ip"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+}
+
+type evaluateResponse struct {
+	Decision Decision `json:"decision"`
+	Matched  string   `json:"matched,omitempty"`
+	Default  bool     `json:"default"`
+}
+
+// evalEnv is the environment server-side evaluations run in. Domains are
+// matched against the DNS cache as it stands; evaluation never waits on a
+// lookup.
+func (s *CentralServer) evalEnv() EvalEnv {
+	env := EvalEnv{Now: s.Clock.Now()}
+	if s.DNS != nil {
+		env.Addrs = s.DNS.Addrs
+	}
+	return env
+}
+
+func (s *CentralServer) HandleEvaluateRule(w http.ResponseWriter, r *http.Request) {
+	appName := mux.Vars(r)["app_name"]
+
+	var req evaluateRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	var ip net.IP
+	if req.IP != "" {
+		if ip = parseIP(req.IP); ip == nil {
+			writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Invalid ip")
+			return
+		}
+	}
+
+	rule, isDefault, err := s.EffectiveRule(r.Context(), appName)
+	if errors.Is(err, ErrRuleNotFound) {
+		writeError(w, http.StatusNotFound, CodeRuleNotFound, "Rule not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+
+	decision, matched := rule.Explain(s.evalEnv(), req.Domain, ip, req.Port, req.Protocol)
+	observeEvaluation(rule.AppName, decision, isDefault)
+	json.NewEncoder(w).Encode(evaluateResponse{Decision: decision, Matched: matched, Default: isDefault})
+}
@@ -0,0 +1,250 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: firewall/v1/firewall.proto
+
+package firewallpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	FirewallService_GetRule_FullMethodName    = "/firewall.v1.FirewallService/GetRule"
+	FirewallService_SetRule_FullMethodName    = "/firewall.v1.FirewallService/SetRule"
+	FirewallService_DeleteRule_FullMethodName = "/firewall.v1.FirewallService/DeleteRule"
+	FirewallService_WatchRules_FullMethodName = "/firewall.v1.FirewallService/WatchRules"
+)
+
+// FirewallServiceClient is the client API for FirewallService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type FirewallServiceClient interface {
+	GetRule(ctx context.Context, in *GetRuleRequest, opts ...grpc.CallOption) (*FirewallRule, error)
+	SetRule(ctx context.Context, in *SetRuleRequest, opts ...grpc.CallOption) (*FirewallRule, error)
+	DeleteRule(ctx context.Context, in *DeleteRuleRequest, opts ...grpc.CallOption) (*DeleteRuleResponse, error)
+	// WatchRules streams every rule change until the client disconnects.
+	WatchRules(ctx context.Context, in *WatchRulesRequest, opts ...grpc.CallOption) (FirewallService_WatchRulesClient, error)
+}
+
+type firewallServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFirewallServiceClient(cc grpc.ClientConnInterface) FirewallServiceClient {
+	return &firewallServiceClient{cc}
+}
+
+func (c *firewallServiceClient) GetRule(ctx context.Context, in *GetRuleRequest, opts ...grpc.CallOption) (*FirewallRule, error) {
+	out := new(FirewallRule)
+	err := c.cc.Invoke(ctx, FirewallService_GetRule_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *firewallServiceClient) SetRule(ctx context.Context, in *SetRuleRequest, opts ...grpc.CallOption) (*FirewallRule, error) {
+	out := new(FirewallRule)
+	err := c.cc.Invoke(ctx, FirewallService_SetRule_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *firewallServiceClient) DeleteRule(ctx context.Context, in *DeleteRuleRequest, opts ...grpc.CallOption) (*DeleteRuleResponse, error) {
+	out := new(DeleteRuleResponse)
+	err := c.cc.Invoke(ctx, FirewallService_DeleteRule_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *firewallServiceClient) WatchRules(ctx context.Context, in *WatchRulesRequest, opts ...grpc.CallOption) (FirewallService_WatchRulesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &FirewallService_ServiceDesc.Streams[0], FirewallService_WatchRules_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &firewallServiceWatchRulesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type FirewallService_WatchRulesClient interface {
+	Recv() (*RuleEvent, error)
+	grpc.ClientStream
+}
+
+type firewallServiceWatchRulesClient struct {
+	grpc.ClientStream
+}
+
+func (x *firewallServiceWatchRulesClient) Recv() (*RuleEvent, error) {
+	m := new(RuleEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FirewallServiceServer is the server API for FirewallService service.
+// All implementations must embed UnimplementedFirewallServiceServer
+// for forward compatibility
+type FirewallServiceServer interface {
+	GetRule(context.Context, *GetRuleRequest) (*FirewallRule, error)
+	SetRule(context.Context, *SetRuleRequest) (*FirewallRule, error)
+	DeleteRule(context.Context, *DeleteRuleRequest) (*DeleteRuleResponse, error)
+	// WatchRules streams every rule change until the client disconnects.
+	WatchRules(*WatchRulesRequest, FirewallService_WatchRulesServer) error
+	mustEmbedUnimplementedFirewallServiceServer()
+}
+
+// UnimplementedFirewallServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedFirewallServiceServer struct {
+}
+
+func (UnimplementedFirewallServiceServer) GetRule(context.Context, *GetRuleRequest) (*FirewallRule, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRule not implemented")
+}
+func (UnimplementedFirewallServiceServer) SetRule(context.Context, *SetRuleRequest) (*FirewallRule, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRule not implemented")
+}
+func (UnimplementedFirewallServiceServer) DeleteRule(context.Context, *DeleteRuleRequest) (*DeleteRuleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteRule not implemented")
+}
+func (UnimplementedFirewallServiceServer) WatchRules(*WatchRulesRequest, FirewallService_WatchRulesServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchRules not implemented")
+}
+func (UnimplementedFirewallServiceServer) mustEmbedUnimplementedFirewallServiceServer() {}
+
+// UnsafeFirewallServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FirewallServiceServer will
+// result in compilation errors.
+type UnsafeFirewallServiceServer interface {
+	mustEmbedUnimplementedFirewallServiceServer()
+}
+
+func RegisterFirewallServiceServer(s grpc.ServiceRegistrar, srv FirewallServiceServer) {
+	s.RegisterService(&FirewallService_ServiceDesc, srv)
+}
+
+func _FirewallService_GetRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FirewallServiceServer).GetRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FirewallService_GetRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FirewallServiceServer).GetRule(ctx, req.(*GetRuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FirewallService_SetRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FirewallServiceServer).SetRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FirewallService_SetRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FirewallServiceServer).SetRule(ctx, req.(*SetRuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FirewallService_DeleteRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FirewallServiceServer).DeleteRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FirewallService_DeleteRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FirewallServiceServer).DeleteRule(ctx, req.(*DeleteRuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FirewallService_WatchRules_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRulesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FirewallServiceServer).WatchRules(m, &firewallServiceWatchRulesServer{stream})
+}
+
+type FirewallService_WatchRulesServer interface {
+	Send(*RuleEvent) error
+	grpc.ServerStream
+}
+
+type firewallServiceWatchRulesServer struct {
+	grpc.ServerStream
+}
+
+func (x *firewallServiceWatchRulesServer) Send(m *RuleEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// FirewallService_ServiceDesc is the grpc.ServiceDesc for FirewallService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var FirewallService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "firewall.v1.FirewallService",
+	HandlerType: (*FirewallServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetRule",
+			Handler:    _FirewallService_GetRule_Handler,
+		},
+		{
+			MethodName: "SetRule",
+			Handler:    _FirewallService_SetRule_Handler,
+		},
+		{
+			MethodName: "DeleteRule",
+			Handler:    _FirewallService_DeleteRule_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchRules",
+			Handler:       _FirewallService_WatchRules_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "firewall/v1/firewall.proto",
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// expectClientOutcome fails unless a fuzzed request ended in a success or a
+// client error: malformed input must never reach a panic or a 5xx.
+func expectClientOutcome(t *testing.T, status int, body string) {
+	t.Helper()
+	if status < http.StatusOK || status >= http.StatusInternalServerError {
+		t.Errorf("status %d for body %q", status, body)
+	}
+}
+
+func FuzzSetRule(f *testing.F) {
+	for _, seed := range []string{
+		`{"app_name": "curl", "allowed_domains": ["example.com"], "allowed_ips": ["10.0.0.0/8"], "enabled": true}`,
+		`{"app_name": "curl", "allowed_ports": ["80-443", "8080"], "blocked_ports": [22], "default_action": "allow"}`,
+		`{"app_name": "curl", "schedule": {"days": ["mon"], "start_time": "09:00", "end_time": "17:00", "timezone": "Europe/London"}, "templates": ["base"]}`,
+		`{"app_name": "curl", "allowed_ips": ["${INTERNAL_CIDR}"], "version": 3}`,
+		`{"app_name": "", "allowed_ips": ["10.0.0.300"]}`,
+		`{"app_name": "curl", "allowed_domains": "example.com"}`,
+		`{"app_name": "curl",`,
+		`[]`,
+		`null`,
+		``,
+	} {
+		f.Add(seed)
+	}
+	h := newTestServer(f).Handler()
+	f.Fuzz(func(t *testing.T, body string) {
+		expectClientOutcome(t, call(t, h, "POST", "/v1/rule", body).Code, body)
+	})
+}
+
+func FuzzReceiveLog(f *testing.F) {
+	for _, seed := range []string{
+		`{"app_name": "curl", "remote_ip": "10.0.0.1", "remote_domain": "example.com", "protocol": "tcp", "port": 443, "action": "blocked"}`,
+		`{"app_name": "curl", "remote_ip": "::1", "port": 53, "action": "allowed", "timestamp": "2024-01-01T00:00:00Z"}`,
+		`{"app_name": "curl", "remote_ip": "10.0.0.300", "port": 70000, "action": "dropped"}`,
+		`{"app_name": "curl", "timestamp": "yesterday"}`,
+		`{"app_name": 7}`,
+		`{`,
+		`null`,
+		``,
+	} {
+		f.Add(seed)
+	}
+	s := newTestServer(f)
+	s.MaxLogs = 100
+	h := s.Handler()
+	f.Fuzz(func(t *testing.T, body string) {
+		expectClientOutcome(t, call(t, h, "POST", "/v1/logs", body).Code, body)
+	})
+}
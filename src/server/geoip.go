@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// GeoIP looks up the country and autonomous system of log remote IPs from
+// MaxMind GeoLite2 databases. Either database may be absent.
+type GeoIP struct {
+	country *geoip2.Reader
+	asn     *geoip2.Reader
+}
+
+// OpenGeoIP opens the Country (or City) and ASN databases; an empty path
+// skips that lookup.
+func OpenGeoIP(countryPath, asnPath string) (*GeoIP, error) {
+	g := &GeoIP{}
+	var err error
+	if countryPath != "" {
+		if g.country, err = geoip2.Open(countryPath); err != nil {
+			return nil, err
+		}
+	}
+	if asnPath != "" {
+		if g.asn, err = geoip2.Open(asnPath); err != nil {
+			g.Close()
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// Enrich fills entry's Country and ASN from its RemoteIP. Addresses the
+// databases don't know, private ones included, leave the fields as they
+// were, which for stored logs is empty.
+func (g *GeoIP) Enrich(entry *NetworkLog) {
+	ip := parseIP(entry.RemoteIP)
+	if ip == nil {
+		return
+	}
+	if g.country != nil {
+		if rec, err := g.country.Country(ip); err == nil && rec.Country.IsoCode != "" {
+			entry.Country = rec.Country.IsoCode
+		}
+	}
+	if g.asn != nil {
+		if rec, err := g.asn.ASN(ip); err == nil && rec.AutonomousSystemNumber != 0 {
+			entry.ASN = rec.AutonomousSystemNumber
+		}
+	}
+}
+
+func (g *GeoIP) Close() error {
+	var errs []error
+	for _, r := range []*geoip2.Reader{g.country, g.asn} {
+		if r != nil {
+			errs = append(errs, r.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// MaxMind DB data types used by the fixtures below.
+const (
+	mmdbString = 2
+	mmdbUint16 = 5
+	mmdbUint32 = 6
+	mmdbMap    = 7
+)
+
+// mmdbField encodes a value of type typ whose payload is data. Sizes stay
+// under 29, so the control byte holds them directly.
+func mmdbField(typ byte, data []byte) []byte {
+	return append([]byte{typ<<5 | byte(len(data))}, data...)
+}
+
+func mmdbStr(s string) []byte { return mmdbField(mmdbString, []byte(s)) }
+
+func mmdbUint(typ byte, v uint32) []byte {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	return mmdbField(typ, bytes.TrimLeft(b[:], "\x00"))
+}
+
+// mmdbMapOf encodes a map from alternating keys and encoded values.
+func mmdbMapOf(kv ...any) []byte {
+	out := []byte{mmdbMap<<5 | byte(len(kv)/2)}
+	for i := 0; i < len(kv); i += 2 {
+		out = append(out, mmdbStr(kv[i].(string))...)
+		out = append(out, kv[i+1].([]byte)...)
+	}
+	return out
+}
+
+// writeMMDB writes an IPv4 MaxMind DB of databaseType in which 0.0.0.0/1
+// maps to record and 128.0.0.0/1 has no data. Its search tree is a single
+// node with 24-bit records: a record equal to the node count means no data,
+// and one past the 16-byte data separator points into the data section.
+func writeMMDB(t *testing.T, databaseType string, record []byte) string {
+	t.Helper()
+	const nodeCount = 1
+	var db bytes.Buffer
+	db.Write([]byte{0, 0, nodeCount + 16, 0, 0, nodeCount})
+	db.Write(make([]byte, 16))
+	db.Write(record)
+	db.WriteString("\xab\xcd\xefMaxMind.com")
+	db.Write(mmdbMapOf(
+		"node_count", mmdbUint(mmdbUint32, nodeCount),
+		"record_size", mmdbUint(mmdbUint16, 24),
+		"ip_version", mmdbUint(mmdbUint16, 4),
+		"database_type", mmdbStr(databaseType),
+		"binary_format_major_version", mmdbUint(mmdbUint16, 2),
+	))
+	path := filepath.Join(t.TempDir(), databaseType+".mmdb")
+	if err := os.WriteFile(path, db.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGeoIPEnrichesLogs(t *testing.T) {
+	country := writeMMDB(t, "GeoLite2-Country", mmdbMapOf("country", mmdbMapOf("iso_code", mmdbStr("GB"))))
+	asn := writeMMDB(t, "GeoLite2-ASN", mmdbMapOf("autonomous_system_number", mmdbUint(mmdbUint32, 64496)))
+	geo, err := OpenGeoIP(country, asn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer geo.Close()
+
+	s := newTestServer(t)
+	s.GeoIP = geo
+	h := s.Handler()
+	for _, ip := range []string{"81.2.69.142", "203.0.113.1"} {
+		entry := NetworkLog{AppName: "curl", RemoteIP: ip, Port: 443, Action: ActionBlocked}
+		expect(t, call(t, h, "POST", "/v1/logs", entry), http.StatusCreated)
+	}
+
+	var page logPage
+	decode(t, call(t, h, "GET", "/v1/logs", nil), &page)
+	if len(page.Items) != 2 {
+		t.Fatalf("%d logs stored, want 2", len(page.Items))
+	}
+	unknown, known := page.Items[0], page.Items[1]
+	if known.Country != "GB" || known.ASN != 64496 {
+		t.Errorf("%s tagged %q AS%d, want GB AS64496", known.RemoteIP, known.Country, known.ASN)
+	}
+	if unknown.Country != "" || unknown.ASN != 0 {
+		t.Errorf("%s tagged %q AS%d, want it untagged", unknown.RemoteIP, unknown.Country, unknown.ASN)
+	}
+
+	var stats logStats
+	decode(t, call(t, h, "GET", "/v1/logs/stats", nil), &stats)
+	if len(stats.ByCountry) != 1 || stats.ByCountry["GB"] != 1 {
+		t.Errorf("by_country = %v, want GB: 1", stats.ByCountry)
+	}
+}
+
+func TestGeoIPIsOptional(t *testing.T) {
+	geo, err := OpenGeoIP("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := NetworkLog{RemoteIP: "81.2.69.142"}
+	geo.Enrich(&entry)
+	if entry.Country != "" || entry.ASN != 0 {
+		t.Errorf("enriched without databases: %+v", entry)
+	}
+	if _, err := OpenGeoIP(filepath.Join(t.TempDir(), "missing.mmdb"), ""); err == nil {
+		t.Error("opened a missing database")
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"server/firewallpb"
+)
+
+//go:generate buf generate proto
+
+// grpcService adapts CentralServer's rule operations to the generated
+// FirewallService interface.
+type grpcService struct {
+	firewallpb.UnimplementedFirewallServiceServer
+	s *CentralServer
+}
+
+func ruleToProto(rule FirewallRule) *firewallpb.FirewallRule {
+	pb := &firewallpb.FirewallRule{
+		AppName:        rule.AppName,
+		AllowedDomains: rule.AllowedDomains,
+		AllowedIps:     rule.AllowedIPs,
+		BlockedDomains: rule.BlockedDomains,
+		BlockedIps:     rule.BlockedIPs,
+		DefaultAction:  string(rule.DefaultAction),
+		Templates:      rule.Templates,
+		Template:       rule.Template,
+		Disabled:       !rule.Enabled,
+		Version:        int64(rule.Version),
+		UpdatedAt:      timestamppb.New(rule.UpdatedAt),
+	}
+	for _, p := range rule.AllowedProtocols {
+		pb.AllowedProtocols = append(pb.AllowedProtocols, string(p))
+	}
+	for _, p := range rule.AllowedPorts {
+		pb.AllowedPorts = append(pb.AllowedPorts, p.String())
+	}
+	for _, p := range rule.BlockedPorts {
+		pb.BlockedPorts = append(pb.BlockedPorts, int32(p))
+	}
+	if sc := rule.Schedule; sc != nil {
+		pb.Schedule = &firewallpb.Schedule{
+			Days:      sc.Days,
+			StartTime: sc.StartTime,
+			EndTime:   sc.EndTime,
+			Timezone:  sc.Timezone,
+		}
+	}
+	return pb
+}
+
+func ruleFromProto(pb *firewallpb.FirewallRule) (FirewallRule, error) {
+	rule := FirewallRule{
+		AppName:        pb.GetAppName(),
+		AllowedDomains: pb.GetAllowedDomains(),
+		AllowedIPs:     pb.GetAllowedIps(),
+		BlockedDomains: pb.GetBlockedDomains(),
+		BlockedIPs:     pb.GetBlockedIps(),
+		DefaultAction:  Decision(pb.GetDefaultAction()),
+		Templates:      pb.GetTemplates(),
+		Template:       pb.GetTemplate(),
+		Enabled:        !pb.GetDisabled(),
+		Version:        int(pb.GetVersion()),
+	}
+	if sc := pb.GetSchedule(); sc != nil {
+		rule.Schedule = &Schedule{
+			Days:      sc.GetDays(),
+			StartTime: sc.GetStartTime(),
+			EndTime:   sc.GetEndTime(),
+			Timezone:  sc.GetTimezone(),
+		}
+	}
+	for _, v := range pb.GetAllowedProtocols() {
+		p, err := ParseProtocol(v)
+		if err != nil {
+			return rule, err
+		}
+		rule.AllowedProtocols = append(rule.AllowedProtocols, p)
+	}
+	for _, v := range pb.GetAllowedPorts() {
+		p, err := ParsePortRange(v)
+		if err != nil {
+			return rule, err
+		}
+		rule.AllowedPorts = append(rule.AllowedPorts, p)
+	}
+	for _, p := range pb.GetBlockedPorts() {
+		rule.BlockedPorts = append(rule.BlockedPorts, int(p))
+	}
+	return rule, nil
+}
+
+func grpcError(err error) error {
+	switch {
+	case errors.Is(err, ErrRuleNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrInvalidRule):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrVersionConflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, ErrTemplateInUse):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, ErrReadOnly):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func (g *grpcService) GetRule(ctx context.Context, req *firewallpb.GetRuleRequest) (*firewallpb.FirewallRule, error) {
+	rule, err := g.s.GetRule(ctx, req.GetAppName())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return ruleToProto(rule), nil
+}
+
+func (g *grpcService) SetRule(ctx context.Context, req *firewallpb.SetRuleRequest) (*firewallpb.FirewallRule, error) {
+	rule, err := ruleFromProto(req.GetRule())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	rule, err = g.s.SetRule(ctx, rule)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return ruleToProto(rule), nil
+}
+
+func (g *grpcService) DeleteRule(ctx context.Context, req *firewallpb.DeleteRuleRequest) (*firewallpb.DeleteRuleResponse, error) {
+	if err := g.s.DeleteRule(ctx, req.GetAppName()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &firewallpb.DeleteRuleResponse{}, nil
+}
+
+func (g *grpcService) WatchRules(req *firewallpb.WatchRulesRequest, stream firewallpb.FirewallService_WatchRulesServer) error {
+	ch := g.s.subscribeRules()
+	defer g.s.unsubscribeRules(ch)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-g.s.closing:
+			return nil
+		case event, ok := <-ch:
+			if !ok {
+				return status.Error(codes.Unavailable, "watcher fell behind; reconnect to resync")
+			}
+			pb := &firewallpb.RuleEvent{Type: event.Type, AppName: event.AppName}
+			if event.Rule != nil {
+				pb.Rule = ruleToProto(*event.Rule)
+			}
+			if err := stream.Send(pb); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// grpcReadMethods may be called without a token unless AuthReads is set,
+// and are the only methods served when GRPCReadOnly is.
+var grpcReadMethods = map[string]bool{
+	firewallpb.FirewallService_GetRule_FullMethodName:    true,
+	firewallpb.FirewallService_WatchRules_FullMethodName: true,
+}
+
+// grpcAuthorize applies the same bearer-token policy as the REST routes,
+// reading the token from the "authorization" metadata key. With
+// GRPCReadOnly set, methods other than reads are refused outright.
+func (s *CentralServer) grpcAuthorize(ctx context.Context, method string) (context.Context, error) {
+	if s.GRPCReadOnly && !grpcReadMethods[method] {
+		return ctx, status.Error(codes.PermissionDenied, "rule changes are only accepted on the admin listener")
+	}
+	if grpcReadMethods[method] && !s.AuthReads {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	var token string
+	if values := md.Get("authorization"); len(values) > 0 {
+		token, _ = strings.CutPrefix(values[0], "Bearer ")
+	}
+	subject, ok := s.authenticate(token)
+	if !ok {
+		return ctx, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	return context.WithValue(ctx, actorKey, subject), nil
+}
+
+type authedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (a authedStream) Context() context.Context {
+	return a.ctx
+}
+
+func (s *CentralServer) newGRPCServer() (*grpc.Server, error) {
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			ctx, err := s.grpcAuthorize(ctx, info.FullMethod)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, err := s.grpcAuthorize(ss.Context(), info.FullMethod)
+			if err != nil {
+				return err
+			}
+			return handler(srv, authedStream{ServerStream: ss, ctx: ctx})
+		}),
+	}
+	if s.TLSCertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(s.TLSCertFile, s.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading gRPC TLS credentials: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	srv := grpc.NewServer(opts...)
+	firewallpb.RegisterFirewallServiceServer(srv, &grpcService{s: s})
+	return srv, nil
+}
+
+// RunGRPC serves the gRPC API until Shutdown is called, returning nil then.
+func (s *CentralServer) RunGRPC(addr string) error {
+	srv, err := s.newGRPCServer()
+	if err != nil {
+		return err
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	s.httpMu.Lock()
+	select {
+	case <-s.closing:
+		s.httpMu.Unlock()
+		lis.Close()
+		return nil
+	default:
+	}
+	s.grpcServer = srv
+	s.httpMu.Unlock()
+
+	slog.Info("listening", "addr", addr, "protocol", "grpc", "tls", s.TLSCertFile != "")
+	if err := srv.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"server/firewallpb"
+)
+
+// newGRPCClient serves s's gRPC API over an in-memory listener.
+func newGRPCClient(t *testing.T, s *CentralServer) firewallpb.FirewallServiceClient {
+	t.Helper()
+	srv, err := s.newGRPCServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return firewallpb.NewFirewallServiceClient(conn)
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestGRPCRuleLifecycle(t *testing.T) {
+	client := newGRPCClient(t, newTestServer(t))
+	ctx := withToken(testToken)
+
+	set, err := client.SetRule(ctx, &firewallpb.SetRuleRequest{Rule: &firewallpb.FirewallRule{
+		AppName: "curl", AllowedDomains: []string{"example.com"}, AllowedPorts: []string{"443"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.GetVersion() != 1 || set.GetDisabled() {
+		t.Errorf("SetRule = %v", set)
+	}
+
+	got, err := client.GetRule(context.Background(), &firewallpb.GetRuleRequest{AppName: "curl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetAllowedDomains()[0] != "example.com" || got.GetAllowedPorts()[0] != "443" {
+		t.Errorf("GetRule = %v", got)
+	}
+
+	stale := &firewallpb.FirewallRule{AppName: "curl", Version: 7}
+	if _, err := client.SetRule(ctx, &firewallpb.SetRuleRequest{Rule: stale}); status.Code(err) != codes.Aborted {
+		t.Errorf("stale SetRule: %v, want Aborted", err)
+	}
+
+	if _, err := client.DeleteRule(ctx, &firewallpb.DeleteRuleRequest{AppName: "curl"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetRule(ctx, &firewallpb.GetRuleRequest{AppName: "curl"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetRule after delete: %v, want NotFound", err)
+	}
+}
+
+func TestGRPCAuth(t *testing.T) {
+	s := newTestServer(t)
+	client := newGRPCClient(t, s)
+	rule := &firewallpb.FirewallRule{AppName: "curl", AllowedDomains: []string{"example.com"}}
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		authReads bool
+		call      func(ctx context.Context) error
+		want      codes.Code
+	}{
+		{"set without token", context.Background(), false, func(ctx context.Context) error {
+			_, err := client.SetRule(ctx, &firewallpb.SetRuleRequest{Rule: rule})
+			return err
+		}, codes.Unauthenticated},
+		{"set with wrong token", withToken("nope"), false, func(ctx context.Context) error {
+			_, err := client.SetRule(ctx, &firewallpb.SetRuleRequest{Rule: rule})
+			return err
+		}, codes.Unauthenticated},
+		{"delete without token", context.Background(), false, func(ctx context.Context) error {
+			_, err := client.DeleteRule(ctx, &firewallpb.DeleteRuleRequest{AppName: "curl"})
+			return err
+		}, codes.Unauthenticated},
+		{"anonymous get", context.Background(), false, func(ctx context.Context) error {
+			_, err := client.GetRule(ctx, &firewallpb.GetRuleRequest{AppName: "curl"})
+			return err
+		}, codes.NotFound},
+		{"anonymous get with auth_reads", context.Background(), true, func(ctx context.Context) error {
+			_, err := client.GetRule(ctx, &firewallpb.GetRuleRequest{AppName: "curl"})
+			return err
+		}, codes.Unauthenticated},
+		{"anonymous watch with auth_reads", context.Background(), true, func(ctx context.Context) error {
+			stream, err := client.WatchRules(ctx, &firewallpb.WatchRulesRequest{})
+			if err == nil {
+				_, err = stream.Recv()
+			}
+			return err
+		}, codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.AuthReads = tt.authReads
+			if got := status.Code(tt.call(tt.ctx)); got != tt.want {
+				t.Errorf("code = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGRPCWritesRefusedWithAdminAddr(t *testing.T) {
+	s := newTestServer(t)
+	putRule(t, s.Handler(), FirewallRule{AppName: "curl", AllowedDomains: []string{"example.com"}, Enabled: true})
+	Config{AuthToken: testToken, AdminAddr: "127.0.0.1:9443"}.Apply(s)
+	client := newGRPCClient(t, s)
+	ctx := withToken(testToken)
+
+	rule := &firewallpb.FirewallRule{AppName: "curl", AllowedDomains: []string{"example.org"}}
+	if _, err := client.SetRule(ctx, &firewallpb.SetRuleRequest{Rule: rule}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("SetRule: %v, want PermissionDenied", err)
+	}
+	if _, err := client.DeleteRule(ctx, &firewallpb.DeleteRuleRequest{AppName: "curl"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("DeleteRule: %v, want PermissionDenied", err)
+	}
+	got, err := client.GetRule(ctx, &firewallpb.GetRuleRequest{AppName: "curl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetVersion() != 1 || got.GetAllowedDomains()[0] != "example.com" {
+		t.Errorf("GetRule = %v, want the rule untouched", got)
+	}
+}
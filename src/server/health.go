@@ -0,0 +1,16 @@
+package main
+
+import "net/http"
+
+func (s *CentralServer) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+}
+
+func (s *CentralServer) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	if err := s.store.Ping(r.Context()); err != nil {
+		requestLogger(r).Warn("store not ready", "err", err)
+		writeError(w, http.StatusServiceUnavailable, CodeUnavailable, "Store unavailable")
+		return
+	}
+	w.Write([]byte("ok"))
+}
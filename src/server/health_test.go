@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// unreachableStore is a RuleStore whose backing storage has gone away.
+type unreachableStore struct{ RuleStore }
+
+func (unreachableStore) Ping(context.Context) error { return errors.New("connection refused") }
+
+func TestReadyz(t *testing.T) {
+	s := newTestServer(t)
+	expect(t, call(t, s.Handler(), "GET", "/readyz", nil), http.StatusOK)
+
+	s.store = unreachableStore{s.store}
+	h := s.Handler()
+	rec := call(t, h, "GET", "/readyz", nil)
+	expect(t, rec, http.StatusServiceUnavailable)
+	var apiErr APIError
+	decode(t, rec, &apiErr)
+	if apiErr.Code != CodeUnavailable {
+		t.Errorf("error = %+v, want UNAVAILABLE", apiErr)
+	}
+	expect(t, call(t, h, "GET", "/healthz", nil), http.StatusOK)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const defaultHistoryLimit = 20
+
+// recordHistoryLocked saves a revision of a committed rule. Like auditing, a
+// failure is logged rather than undoing the write. Callers hold s.mu.
+func (s *CentralServer) recordHistoryLocked(ctx context.Context, rule FirewallRule) {
+	if s.HistoryLimit <= 0 {
+		return
+	}
+	// The rule is already committed, so record it even if ctx has expired.
+	if err := s.store.AppendHistory(context.WithoutCancel(ctx), rule, s.HistoryLimit); err != nil {
+		contextLogger(ctx).Error("recording rule history", "app_name", rule.AppName, "version", rule.Version, "err", err)
+	}
+}
+
+func (s *CentralServer) HandleRuleHistory(w http.ResponseWriter, r *http.Request) {
+	appName := mux.Vars(r)["app_name"]
+
+	s.mu.RLock()
+	revisions, err := s.store.History(r.Context(), appName)
+	s.mu.RUnlock()
+	if err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	if len(revisions) == 0 {
+		writeRuleError(w, ErrRuleNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(revisions)
+}
+
+func (s *CentralServer) HandleRollbackRule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appName := vars["app_name"]
+	version, err := strconv.Atoi(vars["version"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Invalid version")
+		return
+	}
+
+	s.mu.Lock()
+	rule, err := s.rollbackLocked(r.Context(), appName, version)
+	s.mu.Unlock()
+	if err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(rule)
+}
+
+// rollbackLocked re-stores a historical revision as a new version rather than
+// reusing its old number. It restores the rule's contents only: whether the
+// rule is enabled stays as it is now, so a rollback never undoes a toggle.
+// The revision is checked as SetRule would check it, since its templates or
+// the variables it names may have gone since. Callers hold s.mu.
+func (s *CentralServer) rollbackLocked(ctx context.Context, appName string, version int) (FirewallRule, error) {
+	current, err := s.store.Get(ctx, appName)
+	if err != nil {
+		return current, err
+	}
+	revisions, err := s.store.History(ctx, appName)
+	if err != nil {
+		return current, err
+	}
+	for _, rule := range revisions {
+		if rule.Version == version {
+			rule.Enabled = current.Enabled
+			if err := s.checkRuleLocked(ctx, rule); err != nil {
+				return current, err
+			}
+			err := s.storeRuleLocked(ctx, &rule, current)
+			return rule, err
+		}
+	}
+	return current, fmt.Errorf("%w: version %d is not in history", ErrRuleNotFound, version)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRuleHistory(t *testing.T) {
+	h := newTestServer(t).Handler()
+	for _, domain := range []string{"one.example", "two.example", "three.example"} {
+		putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{domain}, Enabled: true})
+	}
+
+	rec := call(t, h, "GET", "/v1/rule/curl/history", nil)
+	expect(t, rec, http.StatusOK)
+	var revisions []FirewallRule
+	decode(t, rec, &revisions)
+	if len(revisions) != 3 {
+		t.Fatalf("%d revisions, want 3", len(revisions))
+	}
+	for i, want := range []string{"three.example", "two.example", "one.example"} {
+		if got := revisions[i]; got.Version != 3-i || got.AllowedDomains[0] != want {
+			t.Errorf("revisions[%d] = version %d %v, want version %d [%s]", i, got.Version, got.AllowedDomains, 3-i, want)
+		}
+	}
+
+	expect(t, call(t, h, "GET", "/v1/rule/wget/history", nil), http.StatusNotFound)
+}
+
+func TestRollbackRule(t *testing.T) {
+	h := newTestServer(t).Handler()
+	original := putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"one.example"}, AllowedPorts: []PortRange{{443, 443}}, Enabled: true})
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"two.example"}, Enabled: true})
+
+	rec := call(t, h, "POST", "/v1/rule/curl/rollback/1", nil)
+	expect(t, rec, http.StatusOK)
+	var restored FirewallRule
+	decode(t, rec, &restored)
+	if restored.Version != 3 {
+		t.Errorf("rollback stored version %d, want 3", restored.Version)
+	}
+	restored.Version, restored.UpdatedAt = original.Version, original.UpdatedAt
+	if !reflect.DeepEqual(restored, original) {
+		t.Errorf("rolled back to %+v, want %+v", restored, original)
+	}
+
+	expect(t, call(t, h, "POST", "/v1/rule/curl/rollback/9", nil), http.StatusNotFound)
+}
+
+func TestRollbackRevalidates(t *testing.T) {
+	h := newTestServer(t).Handler()
+	putRule(t, h, FirewallRule{AppName: "base", AllowedDomains: []string{"example.com"}, Template: true, Enabled: true})
+	putRule(t, h, FirewallRule{AppName: "curl", Templates: []string{"base"}, Enabled: true})
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"example.org"}, Enabled: true})
+	expect(t, call(t, h, "DELETE", "/v1/rule/base", nil), http.StatusNoContent)
+
+	rec := call(t, h, "POST", "/v1/rule/curl/rollback/1", nil)
+	expect(t, rec, http.StatusBadRequest)
+	var apiErr APIError
+	decode(t, rec, &apiErr)
+	if apiErr.Code != CodeValidationFailed || !strings.Contains(apiErr.Message, `unknown template "base"`) {
+		t.Errorf("error = %+v, want VALIDATION_FAILED naming the missing template", apiErr)
+	}
+	var current FirewallRule
+	decode(t, call(t, h, "GET", "/v1/rule/curl", nil), &current)
+	if current.Version != 2 {
+		t.Errorf("rejected rollback changed the rule to version %d", current.Version)
+	}
+}
+
+func TestRollbackKeepsEnabled(t *testing.T) {
+	h := newTestServer(t).Handler()
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"one.example"}, Enabled: true})
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"two.example"}, Enabled: true})
+	expect(t, call(t, h, "POST", "/v1/rule/curl/disable", nil), http.StatusOK)
+
+	rec := call(t, h, "POST", "/v1/rule/curl/rollback/1", nil)
+	expect(t, rec, http.StatusOK)
+	var restored FirewallRule
+	decode(t, rec, &restored)
+	if restored.Enabled || restored.Version != 4 || restored.AllowedDomains[0] != "one.example" {
+		t.Errorf("rolled back to %+v, want version 1's domains, still disabled", restored)
+	}
+	expect(t, call(t, h, "GET", "/v1/rule/curl", nil), http.StatusNotFound)
+
+	// Rolling back to a revision stored while disabled leaves it enabled.
+	expect(t, call(t, h, "POST", "/v1/rule/curl/enable", nil), http.StatusOK)
+	expect(t, call(t, h, "POST", "/v1/rule/curl/rollback/3", nil), http.StatusOK)
+	rec = call(t, h, "GET", "/v1/rule/curl", nil)
+	expect(t, rec, http.StatusOK)
+	if decode(t, rec, &restored); !restored.Enabled || restored.AllowedDomains[0] != "two.example" {
+		t.Errorf("rule = %+v, want version 3's domains, enabled", restored)
+	}
+}
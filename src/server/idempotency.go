@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// idempotentResult is the response first given for an Idempotency-Key. It is
+// pending until that request finishes.
+type idempotentResult struct {
+	bodyHash [sha256.Size]byte
+	pending  bool
+	status   int
+	ctype    string
+	body     []byte
+	expires  time.Time
+}
+
+// idempotencyCache replays responses to retried writes carrying the same
+// Idempotency-Key within ttl. A zero ttl disables it.
+type idempotencyCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	results   map[string]*idempotentResult
+	lastSweep time.Time
+}
+
+func newIdempotencyCache(ttl time.Duration) *idempotencyCache {
+	return &idempotencyCache{ttl: ttl, results: make(map[string]*idempotentResult)}
+}
+
+// SetTTL changes how long keys are remembered from now on.
+func (c *idempotencyCache) SetTTL(ttl time.Duration) {
+	c.mu.Lock()
+	c.ttl = ttl
+	c.mu.Unlock()
+}
+
+// claim returns the stored result for key, or reserves key for a new request
+// and returns nil. Expired results are swept at most once per ttl.
+func (c *idempotencyCache) claim(key string, bodyHash [sha256.Size]byte) *idempotentResult {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.lastSweep) > c.ttl {
+		for k, res := range c.results {
+			if !res.pending && now.After(res.expires) {
+				delete(c.results, k)
+			}
+		}
+		c.lastSweep = now
+	}
+	if res, ok := c.results[key]; ok && (res.pending || now.Before(res.expires)) {
+		copied := *res
+		return &copied
+	}
+	c.results[key] = &idempotentResult{bodyHash: bodyHash, pending: true}
+	return nil
+}
+
+// finish stores the response for a claimed key. Server errors release the
+// key instead, so the client's retry is applied afresh.
+func (c *idempotencyCache) finish(key string, rec *idempotencyRecorder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := c.results[key]
+	if res == nil {
+		return
+	}
+	if rec.status >= 500 {
+		delete(c.results, key)
+		return
+	}
+	res.pending = false
+	res.status = rec.status
+	res.ctype = rec.Header().Get("Content-Type")
+	res.body = rec.body.Bytes()
+	res.expires = time.Now().Add(c.ttl)
+}
+
+// idempotencyRecorder passes a response through while keeping a copy.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Middleware makes h safe to retry with an Idempotency-Key header. Keys are
+// scoped to the authenticated actor, so it must run inside RequireAuth. A
+// repeated key replays the stored response; reusing a key with a different
+// body or query is a 422, and one still being processed is a 409.
+func (c *idempotencyCache) Middleware(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		c.mu.Lock()
+		enabled := c.ttl > 0
+		c.mu.Unlock()
+		if key == "" || !enabled {
+			h(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.New()
+		io.WriteString(hash, r.URL.RawQuery)
+		hash.Write([]byte{0})
+		hash.Write(body)
+		var sum [sha256.Size]byte
+		copy(sum[:], hash.Sum(nil))
+
+		scoped := Actor(r.Context()) + "\x00" + key
+		if res := c.claim(scoped, sum); res != nil {
+			switch {
+			case res.bodyHash != sum:
+				writeError(w, http.StatusUnprocessableEntity, CodeIdempotencyKeyReused, "Idempotency-Key was already used with a different request")
+			case res.pending:
+				writeError(w, http.StatusConflict, CodeRequestInProgress, "A request with this Idempotency-Key is still in progress")
+			default:
+				if res.ctype != "" {
+					w.Header().Set("Content-Type", res.ctype)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(res.status)
+				w.Write(res.body)
+			}
+			return
+		}
+
+		rec := &idempotencyRecorder{ResponseWriter: w}
+		completed := false
+		defer func() {
+			if !completed {
+				// h panicked; don't pin the key to a half-written response.
+				rec.status = http.StatusInternalServerError
+			}
+			c.finish(scoped, rec)
+		}()
+		h(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		completed = true
+	}
+}
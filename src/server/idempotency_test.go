@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestIdempotentSetRule(t *testing.T) {
+	h := newTestServer(t).Handler()
+	post := func(body string) *http.Request {
+		req := newRequest(t, "POST", "/v1/rule", body)
+		req.Header.Set("Idempotency-Key", "create-curl")
+		return req
+	}
+	body := `{"app_name": "curl", "allowed_domains": ["example.com"], "enabled": true}`
+
+	first := serve(h, post(body))
+	expect(t, first, http.StatusCreated)
+	replay := serve(h, post(body))
+	expect(t, replay, http.StatusCreated)
+	if replay.Header().Get("Idempotent-Replayed") != "true" || replay.Body.String() != first.Body.String() {
+		t.Errorf("retry was not replayed: %s", replay.Body)
+	}
+
+	rec := serve(h, post(`{"app_name": "curl", "allowed_domains": ["example.org"], "enabled": true}`))
+	expect(t, rec, http.StatusUnprocessableEntity)
+	var apiErr APIError
+	decode(t, rec, &apiErr)
+	if apiErr.Code != CodeIdempotencyKeyReused {
+		t.Errorf("error = %+v, want %s", apiErr, CodeIdempotencyKeyReused)
+	}
+
+	var entries []AuditEntry
+	decode(t, call(t, h, "GET", "/v1/audit?app_name=curl", nil), &entries)
+	if len(entries) != 1 || entries[0].After.Version != 1 {
+		t.Errorf("%d audit entries, want the one create", len(entries))
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	logArchiveQueueSize = 4096
+	logArchiveBatchSize = 200
+	logArchiveInterval  = time.Second
+	logArchiveTimeout   = 10 * time.Second
+)
+
+// LogArchive is implemented by stores that keep logs beyond the in-memory
+// buffer, which only ever holds this process's recent logs.
+type LogArchive interface {
+	ArchiveLogs(ctx context.Context, logs []NetworkLog) error
+}
+
+// logArchiver writes stored logs to a LogArchive in batches, off the
+// ingestion path. Like webhooks it drops logs rather than slow agents when
+// its queue is full, and it sees only new records: later duplicates folded
+// into one by LogDedupWindow are not archived.
+type logArchiver struct {
+	archive LogArchive
+	queue   chan NetworkLog
+	done    chan struct{}
+}
+
+func newLogArchiver(archive LogArchive) *logArchiver {
+	return &logArchiver{
+		archive: archive,
+		queue:   make(chan NetworkLog, logArchiveQueueSize),
+		done:    make(chan struct{}),
+	}
+}
+
+// add queues entry for archiving. It never blocks.
+func (a *logArchiver) add(entry NetworkLog) {
+	select {
+	case a.queue <- entry:
+	default:
+		logsArchivedTotal.WithLabelValues("dropped").Inc()
+	}
+}
+
+// StartLogArchive archives queued logs until ctx is done, then writes what
+// is still queued. WaitLogArchive waits for that. It does nothing when the
+// store keeps no archive.
+func (s *CentralServer) StartLogArchive(ctx context.Context) {
+	a := s.archive
+	if a == nil {
+		return
+	}
+	go func() {
+		defer close(a.done)
+		ticker := time.NewTicker(logArchiveInterval)
+		defer ticker.Stop()
+		var batch []NetworkLog
+		for {
+			select {
+			case <-ctx.Done():
+				for {
+					select {
+					case entry := <-a.queue:
+						batch = append(batch, entry)
+					default:
+						a.write(batch)
+						return
+					}
+				}
+			case entry := <-a.queue:
+				if batch = append(batch, entry); len(batch) >= logArchiveBatchSize {
+					batch = a.write(batch)
+				}
+			case <-ticker.C:
+				batch = a.write(batch)
+			}
+		}
+	}()
+}
+
+// WaitLogArchive blocks until the archiver started by StartLogArchive has
+// stopped.
+func (s *CentralServer) WaitLogArchive() {
+	if s.archive != nil {
+		<-s.archive.done
+	}
+}
+
+// write archives batch and returns it emptied for reuse. A failed batch is
+// counted and logged, not retried.
+func (a *logArchiver) write(batch []NetworkLog) []NetworkLog {
+	if len(batch) == 0 {
+		return batch
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), logArchiveTimeout)
+	defer cancel()
+	if err := a.archive.ArchiveLogs(ctx, batch); err != nil {
+		logsArchivedTotal.WithLabelValues("failed").Add(float64(len(batch)))
+		slog.Warn("archiving logs failed", "count", len(batch), "err", err)
+	} else {
+		logsArchivedTotal.WithLabelValues("archived").Add(float64(len(batch)))
+	}
+	clear(batch)
+	return batch[:0]
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryArchive records archived batches.
+type memoryArchive struct {
+	mu      sync.Mutex
+	batches [][]NetworkLog
+}
+
+func (a *memoryArchive) ArchiveLogs(_ context.Context, logs []NetworkLog) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.batches = append(a.batches, append([]NetworkLog(nil), logs...))
+	return nil
+}
+
+func TestLogArchiveFlushesOnStop(t *testing.T) {
+	s := newTestServer(t)
+	s.LogDedupWindow = time.Minute
+	archive := &memoryArchive{}
+	s.archive = newLogArchiver(archive)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.StartLogArchive(ctx)
+
+	h := s.Handler()
+	now := time.Now().UTC()
+	for _, port := range []int{443, 443, 80} {
+		entry := NetworkLog{AppName: "curl", RemoteIP: "10.0.0.1", Port: port, Action: ActionAllowed, Timestamp: now}
+		expect(t, call(t, h, "POST", "/v1/logs", entry), http.StatusCreated)
+	}
+	cancel()
+	s.WaitLogArchive()
+
+	var ports []int
+	for _, batch := range archive.batches {
+		for _, entry := range batch {
+			ports = append(ports, entry.Port)
+		}
+	}
+	if len(ports) != 2 || ports[0] != 443 || ports[1] != 80 {
+		t.Errorf("archived ports %v, want [443 80] with the duplicate folded", ports)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var logCSVHeader = []string{"id", "app_name", "remote_ip", "remote_domain", "protocol", "port", "action", "timestamp", "country", "asn", "severity", "count", "first_seen", "last_seen"}
+
+func logCSVRecord(entry NetworkLog) []string {
+	asn, count := "", ""
+	if entry.ASN != 0 {
+		asn = strconv.FormatUint(uint64(entry.ASN), 10)
+	}
+	if entry.Count != 0 {
+		count = strconv.Itoa(entry.Count)
+	}
+	return []string{
+		strconv.FormatUint(entry.ID, 10),
+		entry.AppName,
+		entry.RemoteIP,
+		entry.RemoteDomain,
+		entry.Protocol,
+		strconv.Itoa(entry.Port),
+		entry.Action,
+		entry.Timestamp.UTC().Format(time.RFC3339Nano),
+		entry.Country,
+		asn,
+		string(entry.Severity),
+		count,
+		csvTime(entry.FirstSeen),
+		csvTime(entry.LastSeen),
+	}
+}
+
+// csvTime formats an optional timestamp, leaving the cell empty when unset.
+func csvTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// HandleExportLogs downloads the logs matching the GET /logs filters, newest
+// first, as a JSON array or, with format=csv, CSV with a header row. Rows
+// are written straight to the response as they are encoded.
+func (s *CentralServer) HandleExportLogs(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, `format must be "json" or "csv"`)
+		return
+	}
+	f, err := parseLogFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Invalid log filter: "+err.Error())
+		return
+	}
+	logs := s.filteredLogs(f)
+
+	w.Header().Set("Content-Disposition", `attachment; filename="logs.`+format+`"`)
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(logs)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	cw := csv.NewWriter(w)
+	cw.Write(logCSVHeader)
+	for _, entry := range logs {
+		if err := cw.Write(logCSVRecord(entry)); err != nil {
+			// The client went away; the status line is long gone.
+			requestLogger(r).Debug("log export aborted", "err", err)
+			return
+		}
+	}
+	cw.Flush()
+}
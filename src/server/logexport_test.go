@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExportLogsCSV(t *testing.T) {
+	h := newTestServer(t).Handler()
+	for port := 1; port <= 3; port++ {
+		entry := NetworkLog{AppName: "curl", RemoteIP: "10.0.0.1", RemoteDomain: "example.com", Port: port, Action: ActionBlocked}
+		expect(t, call(t, h, "POST", "/v1/logs", entry), http.StatusCreated)
+	}
+	expect(t, call(t, h, "POST", "/v1/logs", NetworkLog{AppName: "wget", RemoteIP: "10.0.0.2", Port: 80, Action: ActionAllowed}), http.StatusCreated)
+
+	rec := call(t, h, "GET", "/v1/logs/export?format=csv&app_name=curl", nil)
+	expect(t, rec, http.StatusOK)
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); cd != `attachment; filename="logs.csv"` {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := []string{"id", "app_name", "remote_ip", "remote_domain", "protocol", "port", "action", "timestamp", "country", "asn", "severity", "count", "first_seen", "last_seen"}
+	if len(rows) == 0 || !slices.Equal(rows[0], header) {
+		t.Fatalf("header = %v, want %v", rows[:min(len(rows), 1)], header)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("%d rows after the header, want 3", len(rows)-1)
+	}
+	for i, want := range []string{"3", "2", "1"} {
+		if row := rows[i+1]; row[0] != want || row[1] != "curl" || row[3] != "example.com" || row[6] != ActionBlocked {
+			t.Errorf("row %d = %v", i+1, row)
+		}
+	}
+
+	expect(t, call(t, h, "GET", "/v1/logs/export?format=xml", nil), http.StatusBadRequest)
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ActionAllowed = "allowed"
+	ActionBlocked = "blocked"
+)
+
+const defaultMaxLogs = 10000
+
+type NetworkLog struct {
+	// ID is assigned by the server on receipt and increases with every
+	// stored log. It restarts with the server, as the log buffer does.
+	ID           uint64    `json:"id"`
+	AppName      string    `json:"app_name"`
+	RemoteIP     string    `json:"remote_ip"`
+	RemoteDomain string    `json:"remote_domain"`
+	Protocol     string    `json:"protocol"`
+	Port         int       `json:"port"`
+	Action       string    `json:"action"`
+	Timestamp    time.Time `json:"timestamp"`
+
+	// Country (ISO 3166 code) and ASN are filled from RemoteIP when GeoIP
+	// databases are configured. Values sent by agents are discarded.
+	Country string `json:"country,omitempty"`
+	ASN     uint   `json:"asn,omitempty"`
+
+	// Severity is assigned by the server's classifier on ingestion.
+	Severity Severity `json:"severity,omitempty"`
+
+	// With LogDedupWindow set, identical connections are stored once:
+	// Count says how many were collapsed and FirstSeen/LastSeen bound them.
+	// In raw mode they are left empty.
+	Count     int        `json:"count,omitempty"`
+	FirstSeen *time.Time `json:"first_seen,omitempty"`
+	LastSeen  *time.Time `json:"last_seen,omitempty"`
+}
+
+// sameConnection reports whether two logs describe the same connection
+// outcome and so may be collapsed into one record.
+func sameConnection(a, b NetworkLog) bool {
+	return a.AppName == b.AppName && a.RemoteIP == b.RemoteIP && a.RemoteDomain == b.RemoteDomain &&
+		a.Protocol == b.Protocol && a.Port == b.Port && a.Action == b.Action
+}
+
+// lastSeen is when the connection the record stands for was last seen: its
+// LastSeen for a collapsed record, its Timestamp otherwise.
+func (l NetworkLog) lastSeen() time.Time {
+	if l.LastSeen != nil {
+		return *l.LastSeen
+	}
+	return l.Timestamp
+}
+
+// weight is how many received logs the record stands for.
+func (l NetworkLog) weight() int {
+	return max(l.Count, 1)
+}
+
+// Validate reports every malformed field in the log entry in a single error.
+func (l NetworkLog) Validate() error {
+	var problems []string
+	if strings.TrimSpace(l.AppName) == "" {
+		problems = append(problems, "app_name: must not be empty")
+	}
+	if l.Action != ActionAllowed && l.Action != ActionBlocked {
+		problems = append(problems, fmt.Sprintf("action: must be %q or %q", ActionAllowed, ActionBlocked))
+	}
+	if l.RemoteIP != "" && parseIP(l.RemoteIP) == nil {
+		problems = append(problems, fmt.Sprintf("remote_ip: %q is not an IP", l.RemoteIP))
+	}
+	if l.Port < 0 || l.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("port: %d is out of range", l.Port))
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (s *CentralServer) HandleReceiveLogs(w http.ResponseWriter, r *http.Request) {
+	var entry NetworkLog
+	err := json.NewDecoder(r.Body).Decode(&entry)
+	if err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	// Apply the checks the batch endpoint does; an empty or null body would
+	// otherwise be stored as a log with no app.
+	if err := entry.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, CodeValidationFailed, err.Error())
+		return
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+
+	s.logMu.Lock()
+	id := s.appendLogLocked(entry)
+	s.logMu.Unlock()
+	logsReceivedTotal.Inc()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(logReceipt{ID: id})
+}
+
+// logReceipt tells the sender which ID its log was stored under, so a client
+// retrying after a lost response can tell whether the first attempt landed.
+type logReceipt struct {
+	ID uint64 `json:"id"`
+}
+
+type logBatchResult struct {
+	Accepted int           `json:"accepted"`
+	Rejected int           `json:"rejected"`
+	Errors   []importError `json:"errors"`
+	// IDs holds the ID of each accepted entry, in request order.
+	IDs []uint64 `json:"ids"`
+}
+
+// HandleReceiveLogBatch stores every valid entry of a JSON array and reports
+// the rest by index; one bad entry does not reject the batch.
+func (s *CentralServer) HandleReceiveLogBatch(w http.ResponseWriter, r *http.Request) {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	result := logBatchResult{Errors: []importError{}, IDs: []uint64{}}
+	entries := make([]NetworkLog, 0, len(raw))
+	now := time.Now().UTC()
+	for i, msg := range raw {
+		var entry NetworkLog
+		err := json.Unmarshal(msg, &entry)
+		if err == nil {
+			err = entry.Validate()
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, importError{Index: i, Error: err.Error()})
+			continue
+		}
+		if entry.Timestamp.IsZero() {
+			entry.Timestamp = now
+		}
+		entries = append(entries, entry)
+	}
+	result.Accepted = len(entries)
+	result.Rejected = len(result.Errors)
+
+	s.logMu.Lock()
+	for _, entry := range entries {
+		result.IDs = append(result.IDs, s.appendLogLocked(entry))
+	}
+	s.logMu.Unlock()
+	logsReceivedTotal.Add(float64(len(entries)))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// appendLogLocked stores entry under a new ID, evicting the oldest logs
+// beyond MaxLogs, and passes it to live tails, the log archive and, when
+// blocked, webhooks. An entry folded into an existing record by
+// LogDedupWindow takes that record's ID and still reaches live tails but
+// neither the archive nor webhooks. It returns the ID.
+// Callers hold s.logMu.
+func (s *CentralServer) appendLogLocked(entry NetworkLog) uint64 {
+	entry.Count, entry.FirstSeen, entry.LastSeen = 0, nil, nil
+	entry.Country, entry.ASN = "", 0
+	entry.Severity = ""
+	if s.Classify != nil {
+		entry.Severity = s.Classify(entry)
+	}
+	if s.LogDedupWindow > 0 {
+		if i := s.duplicateLogLocked(entry); i >= 0 {
+			rec := &s.Logs[i]
+			rec.Count++
+			if entry.Timestamp.After(*rec.LastSeen) {
+				last := entry.Timestamp
+				rec.LastSeen = &last
+			}
+			entry.ID = rec.ID
+			s.publishLog(entry)
+			return entry.ID
+		}
+		first, last := entry.Timestamp, entry.Timestamp
+		entry.Count, entry.FirstSeen, entry.LastSeen = 1, &first, &last
+	}
+	s.lastLogID++
+	entry.ID = s.lastLogID
+	if s.GeoIP != nil {
+		s.GeoIP.Enrich(&entry)
+	}
+	if s.MaxLogs > 0 && len(s.Logs) >= s.MaxLogs {
+		s.Logs = s.Logs[len(s.Logs)-s.MaxLogs+1:]
+	}
+	s.Logs = append(s.Logs, entry)
+	s.publishLog(entry)
+	if s.archive != nil {
+		s.archive.add(entry)
+	}
+	if entry.Action == ActionBlocked && s.webhooks != nil {
+		s.webhooks.notify(entry)
+	}
+	return entry.ID
+}
+
+// duplicateLogLocked returns the index of the record entry collapses into:
+// the same connection first seen no more than LogDedupWindow before it. The
+// scan runs back from the newest record and stops at the first one older
+// than the window, so agents with badly skewed clocks may get a second
+// record. It returns -1 when there is none. Callers hold s.logMu.
+func (s *CentralServer) duplicateLogLocked(entry NetworkLog) int {
+	cutoff := entry.Timestamp.Add(-s.LogDedupWindow)
+	for i := len(s.Logs) - 1; i >= 0; i-- {
+		rec := s.Logs[i]
+		if rec.FirstSeen == nil || rec.FirstSeen.Before(cutoff) {
+			return -1
+		}
+		if sameConnection(rec, entry) && !entry.Timestamp.Before(*rec.FirstSeen) {
+			return i
+		}
+	}
+	return -1
+}
+
+// pruneLogs drops logs last seen before cutoff and returns how many went. A
+// collapsed record is kept for as long as its connection keeps recurring.
+func (s *CentralServer) pruneLogs(cutoff time.Time) int {
+	s.logMu.Lock()
+	defer s.logMu.Unlock()
+
+	kept := s.Logs[:0]
+	for _, entry := range s.Logs {
+		if !entry.lastSeen().Before(cutoff) {
+			kept = append(kept, entry)
+		}
+	}
+	dropped := len(s.Logs) - len(kept)
+	clear(s.Logs[len(kept):])
+	s.Logs = kept
+	return dropped
+}
+
+// StartLogRetention prunes logs older than LogRetention until ctx is done.
+// LogRetention is re-read on every tick, so a reload can enable, change or
+// disable it; zero keeps every log.
+func (s *CentralServer) StartLogRetention(ctx context.Context) {
+	interval := retentionInterval(s.logRetention())
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				retention := s.logRetention()
+				if retention > 0 {
+					if n := s.pruneLogs(now.Add(-retention)); n > 0 {
+						slog.Debug("pruned expired logs", "count", n)
+					}
+				}
+				if next := retentionInterval(retention); next != interval {
+					interval = next
+					ticker.Reset(interval)
+				}
+			}
+		}
+	}()
+}
+
+// retentionInterval is how often logs are checked for expiry.
+func retentionInterval(retention time.Duration) time.Duration {
+	if retention <= 0 {
+		return time.Minute
+	}
+	return min(retention, time.Minute)
+}
+
+func (s *CentralServer) logRetention() time.Duration {
+	s.liveMu.RLock()
+	defer s.liveMu.RUnlock()
+	return s.LogRetention
+}
+
+const defaultLogLimit = 100
+
+type logFilter struct {
+	AppName  string
+	Action   string
+	Severity Severity
+	Since    time.Time
+	// SinceID keeps only logs stored after the one with that ID.
+	SinceID uint64
+}
+
+func parseLogFilter(r *http.Request) (logFilter, error) {
+	q := r.URL.Query()
+	since, err := parseSince(q.Get("since"))
+	if err != nil {
+		return logFilter{}, err
+	}
+	var sinceID uint64
+	if v := q.Get("since_id"); v != "" {
+		if sinceID, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return logFilter{}, errors.New("invalid since_id")
+		}
+	}
+	return logFilter{
+		AppName:  q.Get("app_name"),
+		Action:   q.Get("action"),
+		Severity: Severity(q.Get("severity")),
+		Since:    since,
+		SinceID:  sinceID,
+	}, nil
+}
+
+// parseSince turns a lookback such as "1h" into the earliest timestamp it
+// covers. An empty value means no lower bound.
+func parseSince(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return time.Time{}, errors.New("invalid since duration")
+	}
+	return time.Now().Add(-d), nil
+}
+
+func (f logFilter) matches(entry NetworkLog) bool {
+	if f.AppName != "" && entry.AppName != f.AppName {
+		return false
+	}
+	if f.Action != "" && entry.Action != f.Action {
+		return false
+	}
+	if f.Severity != "" && entry.Severity != f.Severity {
+		return false
+	}
+	if entry.Timestamp.Before(f.Since) {
+		return false
+	}
+	if entry.ID <= f.SinceID {
+		return false
+	}
+	return true
+}
+
+// filteredLogs returns the stored logs matching f, most recent first.
+func (s *CentralServer) filteredLogs(f logFilter) []NetworkLog {
+	s.logMu.RLock()
+	defer s.logMu.RUnlock()
+
+	matched := []NetworkLog{}
+	for i := len(s.Logs) - 1; i >= 0; i-- {
+		if f.matches(s.Logs[i]) {
+			matched = append(matched, s.Logs[i])
+		}
+	}
+	return matched
+}
+
+// countLogs reports how many stored logs match f.
+func (s *CentralServer) countLogs(f logFilter) int {
+	s.logMu.RLock()
+	defer s.logMu.RUnlock()
+
+	n := 0
+	for _, entry := range s.Logs {
+		if f.matches(entry) {
+			n++
+		}
+	}
+	return n
+}
+
+type logPage struct {
+	Total  int          `json:"total"`
+	Offset int          `json:"offset"`
+	Limit  int          `json:"limit"`
+	Items  []NetworkLog `json:"items"`
+}
+
+func (s *CentralServer) HandleGetLogs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultLogLimit
+	} else if s.MaxLogs > 0 && limit > s.MaxLogs {
+		// No page can hold more than the buffer does.
+		limit = s.MaxLogs
+	}
+	offset, err := strconv.Atoi(q.Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	f, err := parseLogFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Invalid log filter: "+err.Error())
+		return
+	}
+	logs := s.filteredLogs(f)
+	if f.SinceID > 0 {
+		// A cursor reads forward: oldest first, so a client can page by
+		// passing the last ID it saw.
+		slices.Reverse(logs)
+	}
+	page := logPage{Total: len(logs), Offset: offset, Limit: limit, Items: []NetworkLog{}}
+	if offset < len(logs) {
+		page.Items = logs[offset:min(offset+limit, len(logs))]
+	}
+	json.NewEncoder(w).Encode(page)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLogCapEvictsOldest(t *testing.T) {
+	s := newTestServer(t)
+	s.MaxLogs = 3
+	h := s.Handler()
+	for port := 1; port <= 5; port++ {
+		entry := NetworkLog{AppName: "curl", RemoteIP: "10.0.0.1", Port: port, Action: ActionAllowed}
+		expect(t, call(t, h, "POST", "/v1/logs", entry), http.StatusCreated)
+	}
+
+	rec := call(t, h, "GET", "/v1/logs?limit=1000", nil)
+	expect(t, rec, http.StatusOK)
+	var page logPage
+	decode(t, rec, &page)
+	if page.Total != 3 || page.Limit != 3 || len(page.Items) != 3 {
+		t.Fatalf("page = total %d, limit %d, %d items; want 3 of each", page.Total, page.Limit, len(page.Items))
+	}
+	for i, want := range []uint64{5, 4, 3} {
+		if page.Items[i].ID != want {
+			t.Errorf("items[%d].ID = %d, want %d", i, page.Items[i].ID, want)
+		}
+	}
+}
+
+func TestReceiveLogBatch(t *testing.T) {
+	h := newTestServer(t).Handler()
+	body := `[
+		{"app_name": "curl", "remote_ip": "10.0.0.1", "port": 443, "action": "allowed"},
+		{"app_name": "", "action": "allowed"},
+		{"app_name": "curl", "remote_ip": "10.0.0.300", "action": "blocked"},
+		"not a log",
+		{"app_name": "wget", "remote_domain": "example.com", "port": 80, "action": "blocked"}
+	]`
+	rec := call(t, h, "POST", "/v1/logs/batch", body)
+	expect(t, rec, http.StatusOK)
+	var result logBatchResult
+	decode(t, rec, &result)
+	if result.Accepted != 2 || result.Rejected != 3 || len(result.IDs) != 2 {
+		t.Fatalf("result = %+v, want 2 accepted and 3 rejected", result)
+	}
+	for i, want := range []int{1, 2, 3} {
+		if result.Errors[i].Index != want {
+			t.Errorf("errors[%d] is for index %d, want %d", i, result.Errors[i].Index, want)
+		}
+	}
+
+	var page logPage
+	decode(t, call(t, h, "GET", "/v1/logs", nil), &page)
+	if page.Total != 2 || page.Items[0].ID != result.IDs[1] || page.Items[0].AppName != "wget" {
+		t.Errorf("stored %+v, want the two valid entries", page.Items)
+	}
+}
+
+func TestLogDedup(t *testing.T) {
+	s := newTestServer(t)
+	s.LogDedupWindow = time.Minute
+	h := s.Handler()
+	start := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	for i := 0; i < 3; i++ {
+		entry := NetworkLog{AppName: "curl", RemoteIP: "10.0.0.1", Port: 443, Action: ActionBlocked, Timestamp: start.Add(time.Duration(i) * 20 * time.Second)}
+		expect(t, call(t, h, "POST", "/v1/logs", entry), http.StatusCreated)
+	}
+	other := NetworkLog{AppName: "curl", RemoteIP: "10.0.0.1", Port: 80, Action: ActionBlocked, Timestamp: start}
+	expect(t, call(t, h, "POST", "/v1/logs", other), http.StatusCreated)
+
+	var page logPage
+	decode(t, call(t, h, "GET", "/v1/logs", nil), &page)
+	if len(page.Items) != 2 {
+		t.Fatalf("%d records, want the repeats collapsed into one", len(page.Items))
+	}
+	rec := page.Items[1]
+	if rec.Count != 3 || !rec.FirstSeen.Equal(start) || !rec.LastSeen.Equal(start.Add(40*time.Second)) {
+		t.Errorf("collapsed record = count %d, %v to %v", rec.Count, rec.FirstSeen, rec.LastSeen)
+	}
+
+	export := call(t, h, "GET", "/v1/logs/export?format=csv", nil)
+	rows, err := csv.NewReader(export.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Rows are newest first, so the collapsed record is last.
+	row := rows[len(rows)-1]
+	if got := row[len(row)-3:]; !slices.Equal(got, []string{"3", start.Format(time.RFC3339Nano), start.Add(40 * time.Second).Format(time.RFC3339Nano)}) {
+		t.Errorf("CSV count, first_seen, last_seen = %v", got)
+	}
+
+	// Retention goes by when a record was last seen, not first.
+	if dropped := s.pruneLogs(start.Add(30 * time.Second)); dropped != 1 {
+		t.Errorf("pruned %d records, want only the one not seen since start", dropped)
+	}
+	decode(t, call(t, h, "GET", "/v1/logs", nil), &page)
+	if len(page.Items) != 1 || page.Items[0].ID != rec.ID {
+		t.Errorf("kept %+v, want the recurring record", page.Items)
+	}
+}
+
+func TestLogsSinceID(t *testing.T) {
+	h := newTestServer(t).Handler()
+	for port := 1; port <= 5; port++ {
+		entry := NetworkLog{AppName: "curl", RemoteIP: "10.0.0.1", Port: port, Action: ActionAllowed}
+		expect(t, call(t, h, "POST", "/v1/logs", entry), http.StatusCreated)
+	}
+
+	rec := call(t, h, "GET", "/v1/logs?since_id=2", nil)
+	expect(t, rec, http.StatusOK)
+	var page logPage
+	decode(t, rec, &page)
+	var ids []uint64
+	for _, entry := range page.Items {
+		ids = append(ids, entry.ID)
+	}
+	if !slices.Equal(ids, []uint64{3, 4, 5}) {
+		t.Errorf("since_id=2 returned IDs %v, want 3, 4, 5 in that order", ids)
+	}
+
+	decode(t, call(t, h, "GET", "/v1/logs?since_id=5", nil), &page)
+	if len(page.Items) != 0 {
+		t.Errorf("since_id at the newest log returned %d logs", len(page.Items))
+	}
+	expect(t, call(t, h, "GET", "/v1/logs?since_id=-1", nil), http.StatusBadRequest)
+}
+
+func TestReceiveLogDropsClientGeoIP(t *testing.T) {
+	h := newTestServer(t).Handler()
+	body := `{"app_name": "curl", "remote_ip": "10.0.0.1", "port": 443, "action": "blocked", "country": "KP", "asn": 666}`
+	expect(t, call(t, h, "POST", "/v1/logs", body), http.StatusCreated)
+	batch := `[` + body + `]`
+	expect(t, call(t, h, "POST", "/v1/logs/batch", batch), http.StatusOK)
+
+	var page logPage
+	decode(t, call(t, h, "GET", "/v1/logs", nil), &page)
+	for _, entry := range page.Items {
+		if entry.Country != "" || entry.ASN != 0 {
+			t.Errorf("log %d stored with country %q and ASN %d from the client", entry.ID, entry.Country, entry.ASN)
+		}
+	}
+	var stats logStats
+	decode(t, call(t, h, "GET", "/v1/logs/stats", nil), &stats)
+	if len(stats.ByCountry) != 0 {
+		t.Errorf("by_country = %v, want it empty", stats.ByCountry)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	logStreamBuffer    = 64
+	logStreamWriteWait = 10 * time.Second
+	logStreamPingEvery = 30 * time.Second
+)
+
+// The cors middleware has already refused unlisted origins by the time a
+// request reaches the upgrader.
+var logUpgrader = websocket.Upgrader{
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+type logSubscriber struct {
+	ch     chan NetworkLog
+	filter logFilter
+}
+
+func (s *CentralServer) subscribeLogs(f logFilter) *logSubscriber {
+	sub := &logSubscriber{ch: make(chan NetworkLog, logStreamBuffer), filter: f}
+	s.subMu.Lock()
+	s.logSubs[sub] = struct{}{}
+	s.subMu.Unlock()
+	return sub
+}
+
+func (s *CentralServer) unsubscribeLogs(sub *logSubscriber) {
+	s.subMu.Lock()
+	if _, ok := s.logSubs[sub]; ok {
+		delete(s.logSubs, sub)
+		close(sub.ch)
+	}
+	s.subMu.Unlock()
+}
+
+// publishLog fans a stored log out to matching tails. A subscriber whose
+// buffer is full is dropped: its channel is closed and the handler hangs up.
+func (s *CentralServer) publishLog(entry NetworkLog) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	for sub := range s.logSubs {
+		if !sub.filter.matches(entry) {
+			continue
+		}
+		select {
+		case sub.ch <- entry:
+		default:
+			delete(s.logSubs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// HandleLogStream upgrades to a WebSocket and sends each new log matching the
+// app_name and action query filters as a JSON text message.
+func (s *CentralServer) HandleLogStream(w http.ResponseWriter, r *http.Request) {
+	f, err := parseLogFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Invalid log filter: "+err.Error())
+		return
+	}
+	conn, err := logUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written the error response.
+		return
+	}
+	defer conn.Close()
+
+	sub := s.subscribeLogs(f)
+	defer s.unsubscribeLogs(sub)
+
+	// The client never sends anything we care about, but reading is how
+	// close frames and dead connections are noticed.
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(logStreamPingEvery)
+	defer ping.Stop()
+	for {
+		select {
+		case <-gone:
+			return
+		case <-s.closing:
+			closeLogStream(conn, websocket.CloseGoingAway, "server shutting down")
+			return
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(logStreamWriteWait)); err != nil {
+				return
+			}
+		case entry, ok := <-sub.ch:
+			if !ok {
+				closeLogStream(conn, websocket.ClosePolicyViolation, "client too slow")
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(logStreamWriteWait))
+			if err := conn.WriteJSON(entry); err != nil {
+				requestLogger(r).Debug("log stream write failed", "err", err)
+				return
+			}
+		}
+	}
+}
+
+func closeLogStream(conn *websocket.Conn, code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(logStreamWriteWait))
+}
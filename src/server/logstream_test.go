@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialLogStream opens a log tail on srv and waits until it is subscribed.
+func dialLogStream(t *testing.T, s *CentralServer, srv *httptest.Server, query string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/v1/logs/stream" + query
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	for deadline := time.Now().Add(5 * time.Second); s.logSubscribers() == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("stream never subscribed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	return conn
+}
+
+func (s *CentralServer) logSubscribers() int {
+	s.subMu.RLock()
+	defer s.subMu.RUnlock()
+	return len(s.logSubs)
+}
+
+func TestLogStream(t *testing.T) {
+	s := newTestServer(t)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+	conn := dialLogStream(t, s, srv, "?app_name=curl")
+
+	h := s.Handler()
+	expect(t, call(t, h, "POST", "/v1/logs", NetworkLog{AppName: "wget", Action: ActionAllowed}), http.StatusCreated)
+	expect(t, call(t, h, "POST", "/v1/logs", NetworkLog{AppName: "curl", RemoteDomain: "example.com", Action: ActionBlocked}), http.StatusCreated)
+
+	var got NetworkLog
+	if err := conn.ReadJSON(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.AppName != "curl" || got.RemoteDomain != "example.com" || got.ID != 2 {
+		t.Errorf("frame = %+v, want the curl log", got)
+	}
+}
+
+func TestLogStreamClosesSlowClient(t *testing.T) {
+	s := newTestServer(t)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+	conn := dialLogStream(t, s, srv, "")
+
+	// Big entries fill the socket buffers while the client isn't reading,
+	// then the subscriber's channel.
+	entry := NetworkLog{AppName: "curl", RemoteDomain: strings.Repeat("a", 64<<10), Action: ActionAllowed}
+	for i := 0; s.logSubscribers() > 0; i++ {
+		if i == 100000 {
+			t.Fatal("slow subscriber was never dropped")
+		}
+		entry.ID = uint64(i + 1)
+		s.publishLog(entry)
+	}
+
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+			t.Fatalf("stream ended with %v, want a policy-violation close", err)
+		}
+		return
+	}
+}
@@ -1,66 +1,643 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
 )
 
-type FirewallRule struct {
-	AppName          string   `json:"app_name"`
-	AllowedDomains   []string `json:"allowed_domains"`
-	AllowedIPs       []string `json:"allowed_ips"`
-	AllowedProtocols []string `json:"allowed_protocols"`
-}
-
 type CentralServer struct {
-	Rules map[string]FirewallRule
+	mu    sync.RWMutex
+	store RuleStore
+	// readOnly refuses rule changes; see SetReadOnly. Guarded by mu.
+	readOnly bool
+
+	// liveMu guards the settings Reload can change while serving:
+	// AuthToken, AuthTokens, CORSOrigins, LogRetention and config itself.
+	liveMu sync.RWMutex
+	// configPath and config are the file and settings the server was
+	// started with, as amended by Reload.
+	configPath string
+	config     Config
+
+	// Clock is consulted when evaluating scheduled rules.
+	Clock Clock
+	// DNS resolves allowed domains for IP-only evaluations; nil disables it.
+	DNS *DNSCache
+	// GeoIP tags stored logs with country and ASN; nil disables it.
+	GeoIP *GeoIP
+
+	// AuthToken is required on mutating routes, and on reads too when AuthReads
+	// is set. AuthTokens adds further tokens keyed to the subject they act as.
+	AuthToken  string
+	AuthTokens map[string]string
+	AuthReads  bool
+
+	// TLSCertFile and TLSKeyFile switch Run to HTTPS when both are set.
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// GRPCReadOnly refuses the gRPC rule writes, SetRule and DeleteRule. It
+	// is set along with AdminAddr, which gRPC has no counterpart for, so
+	// that rules change only through the admin listener.
+	GRPCReadOnly bool
+
+	// HistoryLimit is how many revisions of each rule are kept.
+	HistoryLimit int
+
+	// MaxBodyBytes caps request bodies; larger ones get a 413.
+	MaxBodyBytes int64
+
+	// RequestTimeout bounds non-streaming API requests; slower ones get a
+	// 503. Zero disables it.
+	RequestTimeout time.Duration
+
+	// Variables resolve ${NAME} placeholders in rules at evaluation time.
+	Variables map[string]string
+
+	// CORSOrigins lists browser origins allowed to call the API; "*" allows any.
+	CORSOrigins []string
+
+	// MaxLogs caps the in-memory log buffer; LogRetention, when non-zero,
+	// also expires logs older than that.
+	MaxLogs      int
+	LogRetention time.Duration
+	// LogDedupWindow, when non-zero, collapses identical logs arriving
+	// within it into one counted record.
+	LogDedupWindow time.Duration
+	// Classify sets each stored log's Severity; nil leaves it empty.
+	Classify LogClassifier
+
+	logMu      sync.RWMutex
+	Logs       []NetworkLog
+	lastLogID  uint64
+	logLimiter *rateLimiter
+	idempotent *idempotencyCache
+	webhooks   *webhookDispatcher
+	archive    *logArchiver
+
+	agentMu sync.RWMutex
+	agents  map[string]Agent
+
+	subMu    sync.RWMutex
+	ruleSubs map[chan RuleEvent]struct{}
+	logSubs  map[*logSubscriber]struct{}
+
+	httpMu      sync.Mutex
+	httpServers []*http.Server
+	grpcServer  *grpc.Server
+	closing     chan struct{}
 }
 
-func NewCentralServer() *CentralServer {
+func NewCentralServer(store RuleStore) *CentralServer {
 	return &CentralServer{
-		Rules: make(map[string]FirewallRule),
+		store:          store,
+		Clock:          systemClock{},
+		DNS:            NewDNSCache(net.DefaultResolver, defaultDNSCacheTTL),
+		MaxLogs:        defaultMaxLogs,
+		MaxBodyBytes:   defaultMaxBodyBytes,
+		RequestTimeout: defaultRequestTimeout,
+		HistoryLimit:   defaultHistoryLimit,
+		logLimiter:     newRateLimiter(defaultLogRate, defaultLogBurst),
+		idempotent:     newIdempotencyCache(defaultIdempotencyTTL),
+		agents:         make(map[string]Agent),
+		ruleSubs:       make(map[chan RuleEvent]struct{}),
+		logSubs:        make(map[*logSubscriber]struct{}),
+		closing:        make(chan struct{}),
 	}
 }
 
+// resolvedRule is a rule as served by GET /rule/{app_name}; Default is set
+// when the app has no rule and the catch-all "*" rule was returned instead.
+type resolvedRule struct {
+	FirewallRule
+	Default bool `json:"default"`
+}
+
 func (s *CentralServer) HandleGetRule(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	appName := vars["app_name"]
 
-	rule, ok := s.Rules[appName]
-	if !ok {
-		http.Error(w, "Rule not found", http.StatusNotFound)
+	rule, isDefault, err := s.ResolveRule(r.Context(), appName)
+	if err != nil {
+		writeRuleError(w, err)
+		return
+	}
+
+	writeETagged(w, r, resolvedRule{FirewallRule: rule, Default: isDefault})
+}
+
+func (s *CentralServer) HandleListRules(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	rules, err := s.store.List(r.Context())
+	s.mu.RUnlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, CodeInternal, err.Error())
 		return
 	}
 
-	json.NewEncoder(w).Encode(rule)
+	json.NewEncoder(w).Encode(rules)
 }
 
 func (s *CentralServer) HandleSetRule(w http.ResponseWriter, r *http.Request) {
 	var rule FirewallRule
 	err := json.NewDecoder(r.Body).Decode(&rule)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeDecodeError(w, err)
+		return
+	}
+
+	if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dryRun {
+		n, err := parseDryRunLogs(r.URL.Query().Get("logs"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+		result, err := s.dryRunRule(r.Context(), rule, n)
+		if err != nil {
+			writeRuleError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(result)
 		return
 	}
 
-	s.Rules[rule.AppName] = rule
+	stored, err := s.SetRule(r.Context(), rule)
+	if err != nil {
+		writeRuleError(w, err)
+		return
+	}
 	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(storedRule{FirewallRule: stored, Warnings: stored.Conflicts()})
 }
 
-func (s *CentralServer) HandleReceiveLogs(w http.ResponseWriter, r *http.Request) {
-	// Implement log receiving logic
+// storedRule answers a successful POST /rule. Warnings lists allow entries
+// that the rule's own block-lists override; they don't stop the write.
+type storedRule struct {
+	FirewallRule
+	Warnings []Conflict `json:"warnings,omitempty"`
 }
 
-func main() {
-	server := NewCentralServer()
+type rulePatch struct {
+	AddDomains    []string `json:"add_domains"`
+	RemoveDomains []string `json:"remove_domains"`
+	AddIPs        []string `json:"add_ips"`
+	RemoveIPs     []string `json:"remove_ips"`
+}
+
+func patchList(list, add, remove []string) []string {
+	drop := make(map[string]bool, len(remove))
+	for _, v := range remove {
+		drop[v] = true
+	}
+	out := []string{}
+	seen := make(map[string]bool, len(list)+len(add))
+	for _, v := range append(append([]string{}, list...), add...) {
+		if drop[v] || seen[v] {
+			continue
+		}
+		seen[v] = true
+		out = append(out, v)
+	}
+	return out
+}
+
+func (p rulePatch) apply(rule FirewallRule) FirewallRule {
+	if len(p.AddDomains) > 0 || len(p.RemoveDomains) > 0 {
+		rule.AllowedDomains = patchList(rule.AllowedDomains, p.AddDomains, p.RemoveDomains)
+	}
+	if len(p.AddIPs) > 0 || len(p.RemoveIPs) > 0 {
+		rule.AllowedIPs = patchList(normalizeIPList(rule.AllowedIPs), normalizeIPList(p.AddIPs), normalizeIPList(p.RemoveIPs))
+	}
+	return rule
+}
+
+func (s *CentralServer) HandlePatchRule(w http.ResponseWriter, r *http.Request) {
+	appName := mux.Vars(r)["app_name"]
+
+	var patch rulePatch
+	err := json.NewDecoder(r.Body).Decode(&patch)
+	if err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	s.mu.Lock()
+	current, err := s.store.Get(r.Context(), appName)
+	if errors.Is(err, ErrRuleNotFound) {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, CodeRuleNotFound, "Rule not found")
+		return
+	}
+	if err != nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+	rule := patch.apply(current)
+	if err := s.validateRule(rule); err != nil {
+		s.mu.Unlock()
+		writeRuleError(w, fmt.Errorf("%w: %v", ErrInvalidRule, err))
+		return
+	}
+	err = s.storeRuleLocked(r.Context(), &rule, current)
+	s.mu.Unlock()
+	if err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(rule)
+}
+
+func (s *CentralServer) HandleDeleteRule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appName := vars["app_name"]
+
+	if err := s.DeleteRule(r.Context(), appName); err != nil {
+		writeRuleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type bulkDeleteResult struct {
+	Deleted  int      `json:"deleted"`
+	AppNames []string `json:"app_names"`
+}
+
+// HandleDeleteRules removes every rule whose app name starts with the
+// required prefix query parameter.
+func (s *CentralServer) HandleDeleteRules(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	if prefix == "" {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "prefix is required")
+		return
+	}
+
+	names, err := s.DeleteRulesWithPrefix(r.Context(), prefix)
+	if err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(bulkDeleteResult{Deleted: len(names), AppNames: names})
+}
+
+// writeRuleError maps errors from the rule operations onto HTTP statuses.
+func writeRuleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrRuleNotFound):
+		writeError(w, http.StatusNotFound, CodeRuleNotFound, "Rule not found")
+	case errors.Is(err, ErrInvalidRule):
+		writeError(w, http.StatusBadRequest, CodeValidationFailed, err.Error())
+	case errors.Is(err, ErrVersionConflict):
+		writeError(w, http.StatusConflict, CodeVersionConflict, err.Error())
+	case errors.Is(err, ErrTemplateInUse):
+		writeError(w, http.StatusConflict, CodeTemplateInUse, err.Error())
+	case errors.Is(err, ErrReadOnly):
+		writeError(w, http.StatusServiceUnavailable, CodeReadOnly, "Server is in read-only mode; rule changes are disabled")
+	default:
+		writeError(w, http.StatusInternalServerError, CodeInternal, err.Error())
+	}
+}
+
+// Routes returns every endpoint, as served by a single listener or by the
+// admin listener when Config.AdminAddr is set.
+func (s *CentralServer) Routes() *mux.Router {
+	return s.routes(true)
+}
+
+// PublicRoutes returns the endpoints served on Config.Addr beside an admin
+// listener: reads, decisions and agent traffic, but nothing that changes
+// rules or server modes.
+func (s *CentralServer) PublicRoutes() *mux.Router {
+	return s.routes(false)
+}
+
+func (s *CentralServer) routes(withAdmin bool) *mux.Router {
 	router := mux.NewRouter()
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, CodeNotFound, "No such endpoint")
+	})
+	router.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+	})
+	router.HandleFunc("/healthz", s.HandleHealthz).Methods("GET")
+	router.HandleFunc("/readyz", s.HandleReadyz).Methods("GET")
+	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	router.HandleFunc("/openapi.json", s.HandleOpenAPI).Methods("GET")
+
+	s.apiRoutes(router, "/v1", nil, withAdmin)
+	s.apiRoutes(router, "", deprecated, withAdmin)
+	// Endpoints added since versioning are registered under /v1 only.
+	v1 := func(method, path string, h http.Handler) {
+		router.Handle("/v1"+path, compress(s.withTimeout(h))).Methods(method)
+	}
+	v1Admin := func(method, path string, h http.Handler) {
+		if withAdmin {
+			v1(method, path, h)
+		}
+	}
+	v1("GET", "/rules/search", s.readAuth(s.HandleSearchRules))
+	v1("GET", "/rule/{app_name}/effective", s.readAuth(s.HandleEffectiveRule))
+	v1Admin("DELETE", "/rules", s.writeAuth(s.HandleDeleteRules))
+	v1("POST", "/rules/diff", s.readAuth(s.HandleDiffRules))
+	v1Admin("POST", "/rule/{app_name}/enable", s.writeAuth(s.handleSetEnabled(true)))
+	v1Admin("POST", "/rule/{app_name}/disable", s.writeAuth(s.handleSetEnabled(false)))
+	v1("GET", "/rules/count", s.readAuth(s.HandleCountRules))
+	v1("GET", "/logs/count", s.readAuth(s.HandleCountLogs))
+	v1("POST", "/decision", s.readAuth(s.HandleDecision))
+	v1("POST", "/simulate", s.readAuth(s.HandleSimulate))
+	v1("GET", "/agents/{id}/policy", s.readAuth(s.HandleAgentPolicy))
+	v1Admin("GET", "/admin/readonly", s.readAuth(s.HandleGetReadOnly))
+	v1Admin("POST", "/admin/readonly", s.writeAuth(s.HandleSetReadOnly))
+	v1Admin("POST", "/admin/reload", s.writeAuth(s.HandleReload))
+	// Exports can outgrow the request timeout, which buffers the whole body.
+	router.Handle("/v1/logs/export", compress(s.readAuth(s.HandleExportLogs))).Methods("GET")
+	return router
+}
+
+// writeAuth guards a mutating endpoint, whose body must be JSON.
+func (s *CentralServer) writeAuth(h http.HandlerFunc) http.Handler {
+	return s.RequireAuth(requireJSON(h))
+}
+
+// readAuth guards a read-only endpoint when AuthReads is set.
+func (s *CentralServer) readAuth(h http.HandlerFunc) http.Handler {
+	if s.AuthReads {
+		return s.RequireAuth(h)
+	}
+	return h
+}
+
+// apiRoutes registers the API as it stood before versioning under prefix,
+// wrapping each handler in mw when it is non-nil. It is served under /v1 and,
+// with a Deprecation header, unprefixed. Rule changes are left out unless
+// withAdmin is set.
+func (s *CentralServer) apiRoutes(router *mux.Router, prefix string, mw mux.MiddlewareFunc, withAdmin bool) {
+	register := func(method, path string, h http.Handler) {
+		if mw != nil {
+			h = mw(h)
+		}
+		router.Handle(prefix+path, h).Methods(method)
+	}
+	handle := func(method, path string, h http.Handler) {
+		register(method, path, compress(s.withTimeout(h)))
+	}
+	admin := func(method, path string, h http.Handler) {
+		if withAdmin {
+			handle(method, path, h)
+		}
+	}
+	// Streams are long-lived by design and skip the request timeout and
+	// compression, both of which buffer.
+	stream := register
+
+	handle("GET", "/rule/{app_name}", s.readAuth(s.HandleGetRule))
+	admin("DELETE", "/rule/{app_name}", s.writeAuth(s.HandleDeleteRule))
+	admin("PATCH", "/rule/{app_name}", s.writeAuth(s.HandlePatchRule))
+	handle("POST", "/rule/{app_name}/evaluate", s.readAuth(s.HandleEvaluateRule))
+	handle("GET", "/rule/{app_name}/history", s.readAuth(s.HandleRuleHistory))
+	admin("POST", "/rule/{app_name}/rollback/{version}", s.writeAuth(s.HandleRollbackRule))
+	admin("POST", "/rule", s.writeAuth(s.idempotent.Middleware(s.HandleSetRule)))
+	handle("GET", "/rules", s.readAuth(s.HandleListRules))
+	stream("GET", "/rules/stream", s.readAuth(s.HandleRuleStream))
+	admin("POST", "/rules/import", s.writeAuth(s.HandleImportRules))
+	handle("GET", "/rules/export", s.readAuth(s.HandleExportRules))
+	handle("POST", "/logs", s.logLimiter.Middleware(s.writeAuth(s.HandleReceiveLogs)))
+	handle("POST", "/logs/batch", s.logLimiter.Middleware(s.writeAuth(s.HandleReceiveLogBatch)))
+	handle("GET", "/logs", s.readAuth(s.HandleGetLogs))
+	stream("GET", "/logs/stream", s.readAuth(s.HandleLogStream))
+	handle("GET", "/logs/stats", s.readAuth(s.HandleLogStats))
+	handle("GET", "/audit", s.readAuth(s.HandleListAudit))
+	handle("GET", "/agents", s.readAuth(s.HandleListAgents))
+	handle("POST", "/agents/register", s.writeAuth(s.HandleRegisterAgent))
+	handle("POST", "/agents/{id}/heartbeat", s.writeAuth(s.HandleAgentHeartbeat))
+}
+
+// deprecated marks unversioned API responses and points at the /v1
+// equivalent.
+func deprecated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "</v1"+r.URL.Path+`>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Handler returns the routes wrapped in the server-wide middleware.
+func (s *CentralServer) Handler() http.Handler {
+	return s.withMiddleware(s.Routes())
+}
+
+// PublicHandler is Handler for PublicRoutes.
+func (s *CentralServer) PublicHandler() http.Handler {
+	return s.withMiddleware(s.PublicRoutes())
+}
+
+func (s *CentralServer) withMiddleware(router *mux.Router) http.Handler {
+	return logRequests(s.cors(limitBody(s.MaxBodyBytes, router)))
+}
+
+// Run serves every route until Shutdown is called, returning nil in that
+// case.
+func (s *CentralServer) Run(addr string) error {
+	return s.serve(addr, "all", s.Handler())
+}
+
+// RunPublic serves PublicRoutes, for use beside Run on a private address.
+func (s *CentralServer) RunPublic(addr string) error {
+	return s.serve(addr, "public", s.PublicHandler())
+}
 
-	router.HandleFunc("/rule/{app_name}", server.HandleGetRule).Methods("GET")
-	router.HandleFunc("/rule", server.HandleSetRule).Methods("POST")
-	router.HandleFunc("/logs", server.HandleReceiveLogs).Methods("POST")
+func (s *CentralServer) serve(addr, routes string, h http.Handler) error {
+	if (s.TLSCertFile == "") != (s.TLSKeyFile == "") {
+		return errors.New("TLS cert and key files must be set together")
+	}
 
-	log.Fatal(http.ListenAndServe(":8080", router))
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	s.httpMu.Lock()
+	select {
+	case <-s.closing:
+		s.httpMu.Unlock()
+		return nil
+	default:
+	}
+	s.httpServers = append(s.httpServers, srv)
+	s.httpMu.Unlock()
+
+	var err error
+	if s.TLSCertFile != "" {
+		slog.Info("listening", "addr", addr, "routes", routes, "tls", true)
+		err = srv.ListenAndServeTLS(s.TLSCertFile, s.TLSKeyFile)
+	} else {
+		slog.Info("listening", "addr", addr, "routes", routes, "tls", false)
+		err = srv.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown ends streaming responses and waits for in-flight requests to
+// finish or ctx to expire.
+func (s *CentralServer) Shutdown(ctx context.Context) error {
+	s.httpMu.Lock()
+	select {
+	case <-s.closing:
+	default:
+		close(s.closing)
+	}
+	servers, grpcSrv := s.httpServers, s.grpcServer
+	s.httpMu.Unlock()
+
+	if grpcSrv != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcSrv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			grpcSrv.Stop()
+		}
+	}
+	// The HTTP servers drain in parallel so one slow listener doesn't eat
+	// the others' share of ctx.
+	errs := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func(srv *http.Server) { errs <- srv.Shutdown(ctx) }(srv)
+	}
+	var err error
+	for range servers {
+		err = errors.Join(err, <-errs)
+	}
+	return err
+}
+
+const shutdownTimeout = 15 * time.Second
+
+func main() {
+	configPath := flag.String("config", "", "path to a JSON config file")
+	validate := flag.Bool("validate", false, "check the config and rules, then exit; rule files may follow, otherwise the store is checked")
+	flag.Parse()
+
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		os.Exit(2)
+	}
+	if *validate {
+		os.Exit(runValidate(cfg, flag.Args(), os.Stdout))
+	}
+	setupLogging(cfg.LogLevel)
+
+	store, err := OpenRuleStore(cfg)
+	if err != nil {
+		slog.Error("opening rule store", "driver", cfg.StorageDriver, "err", err)
+		os.Exit(1)
+	}
+	rules, err := store.List(context.Background())
+	if err != nil {
+		slog.Error("loading rules", "err", err)
+		os.Exit(1)
+	}
+	slog.Info("loaded rules", "count", len(rules), "driver", cfg.StorageDriver)
+
+	server := NewCentralServer(store)
+	server.configPath = *configPath
+	cfg.Apply(server)
+	if archive, ok := store.(LogArchive); ok {
+		server.archive = newLogArchiver(archive)
+	}
+	if err := server.LoadReadOnly(context.Background()); err != nil {
+		slog.Error("loading read-only mode", "err", err)
+		os.Exit(1)
+	}
+	if cfg.GeoIPCountryDB != "" || cfg.GeoIPASNDB != "" {
+		server.GeoIP, err = OpenGeoIP(cfg.GeoIPCountryDB, cfg.GeoIPASNDB)
+		if err != nil {
+			slog.Error("opening GeoIP databases", "err", err)
+			os.Exit(1)
+		}
+	}
+	if server.AuthToken == "" && len(server.AuthTokens) == 0 {
+		slog.Warn("no auth token configured; all mutating requests will be rejected")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	server.StartLogRetention(ctx)
+	server.StartWebhooks(ctx)
+	server.StartLogArchive(ctx)
+	if server.DNS != nil {
+		server.DNS.Start(ctx)
+	}
+
+	listeners := 1
+	errc := make(chan error, 3)
+	if cfg.AdminAddr == "" {
+		go func() { errc <- server.Run(cfg.Addr) }()
+	} else {
+		listeners++
+		go func() { errc <- server.Run(cfg.AdminAddr) }()
+		go func() { errc <- server.RunPublic(cfg.Addr) }()
+	}
+	if cfg.GRPCAddr != "" {
+		listeners++
+		go func() { errc <- server.RunGRPC(cfg.GRPCAddr) }()
+	}
+
+	// The first listener to exit, or a signal, stops everything.
+	var runErr error
+	select {
+	case runErr = <-errc:
+		listeners--
+	case <-ctx.Done():
+	}
+	slog.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("shutdown", "err", err)
+	}
+	cancel()
+	for ; listeners > 0; listeners-- {
+		if err := <-errc; err != nil && runErr == nil {
+			runErr = err
+		}
+	}
+
+	stop()
+	server.WaitLogArchive()
+	if err := store.Close(); err != nil {
+		slog.Error("closing rule store", "err", err)
+	}
+	if server.GeoIP != nil {
+		server.GeoIP.Close()
+	}
+	if runErr != nil {
+		slog.Error("server stopped", "err", runErr)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDeleteRule(t *testing.T) {
+	h := newTestServer(t).Handler()
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"example.com"}, Enabled: true})
+
+	expect(t, call(t, h, "DELETE", "/v1/rule/curl", nil), http.StatusNoContent)
+	expect(t, call(t, h, "GET", "/v1/rule/curl", nil), http.StatusNotFound)
+	expect(t, call(t, h, "DELETE", "/v1/rule/curl", nil), http.StatusNotFound)
+}
+
+func TestHandlerOverTLS(t *testing.T) {
+	s := newTestServer(t)
+	srv := httptest.NewTLSServer(s.Handler())
+	defer srv.Close()
+	client := srv.Client()
+
+	req, _ := http.NewRequest("POST", srv.URL+"/v1/rule", strings.NewReader(`{"app_name": "curl", "allowed_domains": ["example.com"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST over TLS: status %d", resp.StatusCode)
+	}
+
+	resp, err = client.Get(srv.URL + "/v1/rule/curl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var rule resolvedRule
+	if err := json.NewDecoder(resp.Body).Decode(&rule); err != nil {
+		t.Fatal(err)
+	}
+	if resp.TLS == nil || rule.AppName != "curl" {
+		t.Errorf("GET over TLS: tls %v, rule %+v", resp.TLS != nil, rule)
+	}
+}
+
+func TestRunRequiresCertAndKey(t *testing.T) {
+	s := newTestServer(t)
+	s.TLSCertFile = "cert.pem"
+	if err := s.Run("127.0.0.1:0"); err == nil {
+		t.Error("Run with a cert but no key succeeded")
+	}
+}
+
+func TestStaleUpdateConflicts(t *testing.T) {
+	h := newTestServer(t).Handler()
+	rule := putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"example.com"}, Enabled: true})
+
+	first, second := rule, rule
+	first.AllowedDomains = []string{"first.example.com"}
+	second.AllowedDomains = []string{"second.example.com"}
+	expect(t, call(t, h, "POST", "/v1/rule", first), http.StatusCreated)
+	rec := call(t, h, "POST", "/v1/rule", second)
+	expect(t, rec, http.StatusConflict)
+	var apiErr APIError
+	decode(t, rec, &apiErr)
+	if apiErr.Code != CodeVersionConflict || !strings.Contains(apiErr.Message, "version 2") {
+		t.Errorf("error = %+v, want VERSION_CONFLICT naming version 2", apiErr)
+	}
+
+	rec = call(t, h, "GET", "/v1/rule/curl", nil)
+	var got resolvedRule
+	decode(t, rec, &got)
+	if got.Version != 2 || got.AllowedDomains[0] != "first.example.com" {
+		t.Errorf("stored rule = %+v, want the first writer's at version 2", got)
+	}
+}
+
+func TestPatchRule(t *testing.T) {
+	h := newTestServer(t).Handler()
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"example.com"}, AllowedIPs: []string{"10.0.0.0/8", "1.2.3.4"}, Enabled: true})
+
+	rec := call(t, h, "PATCH", "/v1/rule/curl", rulePatch{AddDomains: []string{"example.org", "example.com"}})
+	expect(t, rec, http.StatusOK)
+	var got FirewallRule
+	decode(t, rec, &got)
+	if strings.Join(got.AllowedDomains, ",") != "example.com,example.org" {
+		t.Errorf("allowed_domains = %v", got.AllowedDomains)
+	}
+	if strings.Join(got.AllowedIPs, ",") != "10.0.0.0/8,1.2.3.4" || got.Version != 2 {
+		t.Errorf("PATCH changed more than the domains: %+v", got)
+	}
+
+	expect(t, call(t, h, "PATCH", "/v1/rule/wget", rulePatch{AddDomains: []string{"example.org"}}), http.StatusNotFound)
+}
+
+func TestDefaultRuleFallback(t *testing.T) {
+	h := newTestServer(t).Handler()
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"example.com"}, Enabled: true})
+	expect(t, call(t, h, "GET", "/v1/rule/wget", nil), http.StatusNotFound)
+
+	putRule(t, h, FirewallRule{AppName: DefaultRuleName, AllowedDomains: []string{"updates.example"}, Enabled: true})
+	tests := []struct {
+		app         string
+		wantApp     string
+		wantDefault bool
+	}{
+		{"curl", "curl", false},
+		{"wget", DefaultRuleName, true},
+		{DefaultRuleName, DefaultRuleName, false},
+	}
+	for _, tt := range tests {
+		rec := call(t, h, "GET", "/v1/rule/"+tt.app, nil)
+		expect(t, rec, http.StatusOK)
+		// resolvedRule can't be decoded into: FirewallRule's UnmarshalJSON
+		// is promoted and would drop Default.
+		var got struct {
+			AppName string `json:"app_name"`
+			Default bool   `json:"default"`
+		}
+		decode(t, rec, &got)
+		if got.AppName != tt.wantApp || got.Default != tt.wantDefault {
+			t.Errorf("GET %s = %s (default %v), want %s (default %v)", tt.app, got.AppName, got.Default, tt.wantApp, tt.wantDefault)
+		}
+	}
+
+	rec := call(t, h, "POST", "/v1/rule/wget/evaluate", evaluateRequest{Domain: "updates.example", Port: 443, Protocol: "tcp"})
+	var eval evaluateResponse
+	decode(t, rec, &eval)
+	if eval.Decision != Allow || !eval.Default {
+		t.Errorf("evaluate for an unknown app = %+v, want allow by the default rule", eval)
+	}
+}
+
+func TestVersionedAndDeprecatedRoutes(t *testing.T) {
+	h := newTestServer(t).Handler()
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"example.com"}, Enabled: true})
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{"GET", "/rule/curl", http.StatusOK},
+		{"GET", "/rules", http.StatusOK},
+		{"GET", "/logs", http.StatusOK},
+		{"GET", "/rule/wget", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		current := call(t, h, tt.method, "/v1"+tt.path, nil)
+		legacy := call(t, h, tt.method, tt.path, nil)
+		if current.Code != tt.want || legacy.Code != tt.want || current.Body.String() != legacy.Body.String() {
+			t.Errorf("%s %s: /v1 answered %d, unprefixed %d; want the same %d response", tt.method, tt.path, current.Code, legacy.Code, tt.want)
+		}
+		if current.Header().Get("Deprecation") != "" {
+			t.Errorf("%s /v1%s is marked deprecated", tt.method, tt.path)
+		}
+		if legacy.Header().Get("Deprecation") != "true" || legacy.Header().Get("Link") != `</v1`+tt.path+`>; rel="successor-version"` {
+			t.Errorf("%s %s: Deprecation %q, Link %q", tt.method, tt.path, legacy.Header().Get("Deprecation"), legacy.Header().Get("Link"))
+		}
+	}
+
+	// Endpoints added since versioning have no unprefixed form.
+	expect(t, call(t, h, "GET", "/rules/count", nil), http.StatusNotFound)
+	expect(t, call(t, h, "GET", "/v1/rules/count", nil), http.StatusOK)
+}
+
+func TestDeleteRulesByPrefix(t *testing.T) {
+	h := newTestServer(t).Handler()
+	for _, name := range []string{"legacy-api", "legacy-worker", "legacy", "api"} {
+		putRule(t, h, FirewallRule{AppName: name, Enabled: true})
+	}
+
+	rec := call(t, h, "DELETE", "/v1/rules?prefix=legacy-", nil)
+	expect(t, rec, http.StatusOK)
+	var got bulkDeleteResult
+	decode(t, rec, &got)
+	slices.Sort(got.AppNames)
+	if got.Deleted != 2 || !slices.Equal(got.AppNames, []string{"legacy-api", "legacy-worker"}) {
+		t.Errorf("deleted %+v, want legacy-api and legacy-worker", got)
+	}
+	for _, name := range []string{"legacy-api", "legacy-worker"} {
+		expect(t, call(t, h, "GET", "/v1/rule/"+name, nil), http.StatusNotFound)
+		var entries []AuditEntry
+		decode(t, call(t, h, "GET", "/v1/audit?app_name="+name, nil), &entries)
+		if len(entries) != 2 || entries[0].Action != AuditDelete {
+			t.Errorf("%s: %d audit entries, want its create and delete", name, len(entries))
+		}
+	}
+	for _, name := range []string{"legacy", "api"} {
+		expect(t, call(t, h, "GET", "/v1/rule/"+name, nil), http.StatusOK)
+	}
+
+	rec = call(t, h, "DELETE", "/v1/rules?prefix=nothing", nil)
+	expect(t, rec, http.StatusOK)
+	if decode(t, rec, &got); got.Deleted != 0 {
+		t.Errorf("deleted %+v matching nothing", got)
+	}
+	expect(t, call(t, h, "DELETE", "/v1/rules", nil), http.StatusBadRequest)
+}
+
+func TestAdminListenerSplit(t *testing.T) {
+	s := newTestServer(t)
+	admin := httptest.NewServer(s.Handler())
+	defer admin.Close()
+	public := httptest.NewServer(s.PublicHandler())
+	defer public.Close()
+	// send makes a real request to base; newRequest builds server-side ones.
+	send := func(base, method, path string, body any) int {
+		t.Helper()
+		req := newRequest(t, method, path, body)
+		req.RequestURI = ""
+		u, err := url.Parse(base + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.URL = u
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	rule := FirewallRule{AppName: "curl", AllowedDomains: []string{"example.com"}, Enabled: true}
+	if got := send(public.URL, "POST", "/v1/rule", rule); got != http.StatusNotFound {
+		t.Errorf("POST /v1/rule on the public listener: %d, want 404", got)
+	}
+	if got := send(admin.URL, "POST", "/v1/rule", rule); got != http.StatusCreated {
+		t.Fatalf("POST /v1/rule on the admin listener: %d, want 201", got)
+	}
+	// The public listener serves GET on this path, so other methods are 405.
+	if got := send(public.URL, "DELETE", "/v1/rule/curl", nil); got != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE /v1/rule/curl on the public listener: %d, want 405", got)
+	}
+	decision := decisionRequest{AppName: "curl", Domain: "example.com", Port: 443, Protocol: "tcp"}
+	if got := send(public.URL, "POST", "/v1/decision", decision); got != http.StatusOK {
+		t.Errorf("POST /v1/decision on the public listener: %d, want 200", got)
+	}
+	if got := send(public.URL, "GET", "/v1/rule/curl", nil); got != http.StatusOK {
+		t.Errorf("GET /v1/rule/curl on the public listener: %d, want 200", got)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	rulesSetTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "firewall_rules_set_total",
+		Help: "Rules created or updated.",
+	})
+	rulesDeletedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "firewall_rules_deleted_total",
+		Help: "Rules deleted.",
+	})
+	logsReceivedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "firewall_logs_received_total",
+		Help: "Network logs accepted from agents.",
+	})
+	evaluationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "firewall_evaluations_total",
+		Help: "Connections evaluated by the server, by the app of the deciding rule (\"*\" for the default rule) and decision.",
+	}, []string{"app", "decision"})
+	defaultRuleFallbacksTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "firewall_default_rule_fallbacks_total",
+		Help: "Evaluations for apps without an enabled rule of their own, decided by the default rule or not at all.",
+	})
+	webhookDeliveriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "firewall_webhook_deliveries_total",
+		Help: "Blocked-connection notifications by outcome: delivered, failed or dropped.",
+	}, []string{"result"})
+	logsArchivedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "firewall_logs_archived_total",
+		Help: "Logs sent to the store's log archive by outcome: archived, failed or dropped.",
+	}, []string{"result"})
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "firewall_http_requests_total",
+		Help: "HTTP requests by response status code.",
+	}, []string{"code"})
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "firewall_http_request_duration_seconds",
+		Help:    "HTTP request latency.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+)
+
+// observeEvaluation counts one evaluation. app is the rule that decided it,
+// which keeps the label set bounded by stored rules rather than by whatever
+// app names clients send; it is "" when no rule applied.
+func observeEvaluation(app string, decision Decision, fellBack bool) {
+	evaluationsTotal.WithLabelValues(app, string(decision)).Inc()
+	if fellBack {
+		defaultRuleFallbacksTotal.Inc()
+	}
+}
+
+func observeRequest(method string, status int, seconds float64) {
+	httpRequestsTotal.WithLabelValues(strconv.Itoa(status)).Inc()
+	httpRequestDuration.WithLabelValues(method).Observe(seconds)
+}
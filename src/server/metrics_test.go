@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// scrapeMetric returns the value of metric name from /metrics. A labelled
+// series is named with its labels in order, as in app_total{app="curl"}.
+func scrapeMetric(t *testing.T, h http.Handler, name string) float64 {
+	t.Helper()
+	rec := call(t, h, "GET", "/metrics", nil)
+	expect(t, rec, http.StatusOK)
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), name+" "); ok {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			return v
+		}
+	}
+	t.Fatalf("%s not exported", name)
+	return 0
+}
+
+func TestMetricsCountRuleSets(t *testing.T) {
+	h := newTestServer(t).Handler()
+	before := scrapeMetric(t, h, "firewall_rules_set_total")
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"example.com"}, Enabled: true})
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"example.org"}, Enabled: true})
+	if got := scrapeMetric(t, h, "firewall_rules_set_total"); got != before+2 {
+		t.Errorf("firewall_rules_set_total = %v after two sets, want %v", got, before+2)
+	}
+}
+
+func TestMetricsCountBlockedDecisions(t *testing.T) {
+	h := newTestServer(t).Handler()
+	putRule(t, h, FirewallRule{AppName: "curl", BlockedDomains: []string{"ads.example"}, Enabled: true})
+	putRule(t, h, FirewallRule{AppName: DefaultRuleName, Enabled: true})
+	// Create the series so it is exported before its first increment.
+	evaluationsTotal.WithLabelValues("curl", string(Block))
+	const blocked = `firewall_evaluations_total{app="curl",decision="block"}`
+	before := scrapeMetric(t, h, blocked)
+	fallbacks := scrapeMetric(t, h, "firewall_default_rule_fallbacks_total")
+
+	req := decisionRequest{AppName: "curl", Domain: "ads.example", Port: 443, Protocol: "tcp"}
+	expect(t, call(t, h, "POST", "/v1/decision", req), http.StatusOK)
+	if got := scrapeMetric(t, h, blocked); got != before+1 {
+		t.Errorf("%s = %v after a blocked decision, want %v", blocked, got, before+1)
+	}
+
+	req.AppName = "wget"
+	expect(t, call(t, h, "POST", "/v1/decision", req), http.StatusOK)
+	if got := scrapeMetric(t, h, "firewall_default_rule_fallbacks_total"); got != fallbacks+1 {
+		t.Errorf("firewall_default_rule_fallbacks_total = %v after a fallback, want %v", got, fallbacks+1)
+	}
+	if got := scrapeMetric(t, h, blocked); got != before+1 {
+		t.Errorf("%s counted the default rule's decision", blocked)
+	}
+}
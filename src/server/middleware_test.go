@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimitBody(t *testing.T) {
+	s := newTestServer(t)
+	s.MaxBodyBytes = 256
+	h := s.Handler()
+	rule := func(n int) string {
+		return `{"app_name": "curl", "allowed_domains": ["` + strings.Repeat("a", n) + `.example.com"]}`
+	}
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		want int
+	}{
+		{"rule under cap", "/v1/rule", rule(10), http.StatusCreated},
+		{"rule over cap", "/v1/rule", rule(300), http.StatusRequestEntityTooLarge},
+		{"batch over cap", "/v1/logs/batch", `[` + strings.Repeat(`{"app_name": "curl", "action": "allowed"},`, 10) + `{}]`, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := call(t, h, "POST", tt.path, tt.body)
+			expect(t, rec, tt.want)
+			if tt.want == http.StatusRequestEntityTooLarge {
+				var apiErr APIError
+				decode(t, rec, &apiErr)
+				if apiErr.Code != CodeBodyTooLarge || !strings.Contains(apiErr.Message, "256 bytes") {
+					t.Errorf("error = %+v, want BODY_TOO_LARGE naming the cap", apiErr)
+				}
+			}
+		})
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	s := newTestServer(t)
+	s.RequestTimeout = 20 * time.Millisecond
+	handler := func(delay time.Duration) http.Handler {
+		return s.withTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-time.After(delay):
+				w.Write([]byte("ok"))
+			case <-r.Context().Done():
+			}
+		}))
+	}
+
+	tests := []struct {
+		name  string
+		delay time.Duration
+		want  int
+	}{
+		{"fast", 0, http.StatusOK},
+		{"slow", time.Second, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(handler(tt.delay), newRequest(t, "GET", "/", nil))
+			expect(t, rec, tt.want)
+			if tt.want == http.StatusServiceUnavailable {
+				var apiErr APIError
+				decode(t, rec, &apiErr)
+				if apiErr.Code != CodeTimeout {
+					t.Errorf("error = %+v, want TIMEOUT", apiErr)
+				}
+			}
+		})
+	}
+
+	s.RequestTimeout = 0
+	if rec := serve(handler(50*time.Millisecond), newRequest(t, "GET", "/", nil)); rec.Code != http.StatusOK {
+		t.Errorf("with no timeout: status %d", rec.Code)
+	}
+}
+
+func TestRequireJSON(t *testing.T) {
+	h := newTestServer(t).Handler()
+	body := `{"app_name": "curl", "allowed_domains": ["example.com"]}`
+
+	tests := []struct {
+		name        string
+		contentType string
+		want        int
+	}{
+		{"json", "application/json", http.StatusCreated},
+		{"json with charset", "application/json; charset=utf-8", http.StatusCreated},
+		{"plain text", "text/plain", http.StatusUnsupportedMediaType},
+		{"form", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"missing", "", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest(t, "POST", "/v1/rule", body)
+			req.Header.Set("Content-Type", tt.contentType)
+			rec := serve(h, req)
+			expect(t, rec, tt.want)
+			if tt.want == http.StatusUnsupportedMediaType {
+				var apiErr APIError
+				decode(t, rec, &apiErr)
+				if apiErr.Code != CodeUnsupportedMediaType || rec.Header().Get("Accept") != "application/json" {
+					t.Errorf("error = %+v, Accept %q", apiErr, rec.Header().Get("Accept"))
+				}
+			}
+		})
+	}
+
+	// A bodiless request passes whatever Content-Type it claims.
+	req := newRequest(t, "DELETE", "/v1/rule/curl", nil)
+	req.Header.Set("Content-Type", "text/plain")
+	expect(t, serve(h, req), http.StatusNoContent)
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type apiParam struct {
+	Name        string
+	Description string
+}
+
+// apiOperation documents one route. Request and Response hold a zero value
+// of the body type, or nil when there is none; Also lists other documented
+// statuses the same way.
+type apiOperation struct {
+	Method      string
+	Path        string
+	Summary     string
+	Write       bool
+	Query       []apiParam
+	Request     any
+	Response    any
+	Status      int
+	Also        map[int]any
+	ContentType string
+}
+
+// apiOperations is the source of /openapi.json. Add an entry with every new
+// route; TestOpenAPIDocumentsEveryRoute fails on any that are missing.
+var apiOperations = []apiOperation{
+	{Method: "GET", Path: "/healthz", Summary: "Liveness probe", Status: 200, ContentType: "text/plain"},
+	{Method: "GET", Path: "/readyz", Summary: "Readiness probe; 503 while the store is unreachable", Status: 200, ContentType: "text/plain"},
+	{Method: "GET", Path: "/metrics", Summary: "Prometheus metrics", Status: 200, ContentType: "text/plain"},
+	{Method: "GET", Path: "/openapi.json", Summary: "This document", Status: 200},
+
+	{Method: "GET", Path: "/v1/rule/{app_name}", Summary: "Get an app's rule, falling back to the \"*\" default rule when it has none or it is disabled", Response: resolvedRule{}, Status: 200},
+	{Method: "DELETE", Path: "/v1/rule/{app_name}", Summary: "Delete a rule; 409 for a template other rules still use", Write: true, Status: 204},
+	{Method: "PATCH", Path: "/v1/rule/{app_name}", Summary: "Add or remove allowed domains and IPs", Write: true, Request: rulePatch{}, Response: FirewallRule{}, Status: 200},
+	{Method: "POST", Path: "/v1/rule/{app_name}/evaluate", Summary: "Evaluate a connection against an app's rule", Request: evaluateRequest{}, Response: evaluateResponse{}, Status: 200},
+	{Method: "GET", Path: "/v1/rule/{app_name}/effective", Summary: "Get an app's rule with its templates' lists merged in", Response: resolvedRule{}, Status: 200},
+	{Method: "POST", Path: "/v1/rule/{app_name}/enable", Summary: "Turn a disabled rule back on", Write: true, Response: FirewallRule{}, Status: 200},
+	{Method: "POST", Path: "/v1/rule/{app_name}/disable", Summary: "Keep a rule but evaluate the app as if it had none", Write: true, Response: FirewallRule{}, Status: 200},
+	{Method: "GET", Path: "/v1/rule/{app_name}/history", Summary: "List a rule's stored revisions, newest first", Response: []FirewallRule{}, Status: 200},
+	{Method: "POST", Path: "/v1/rule/{app_name}/rollback/{version}", Summary: "Restore a historical revision's contents as a new version, keeping the enabled state", Write: true, Response: FirewallRule{}, Status: 200},
+	{Method: "POST", Path: "/v1/rule", Summary: "Create or replace a rule; with dry_run, replay recent logs instead of storing. A repeated Idempotency-Key replays the first response, or is a 422 with a different body", Write: true,
+		Query:   []apiParam{{"dry_run", "Return a dryRunResult instead of storing the rule"}, {"logs", "Number of recent logs a dry run replays"}},
+		Request: FirewallRule{}, Response: storedRule{}, Status: 201, Also: map[int]any{200: dryRunResult{}}},
+	{Method: "GET", Path: "/v1/rules", Summary: "List all rules", Response: []FirewallRule{}, Status: 200},
+	{Method: "DELETE", Path: "/v1/rules", Summary: "Atomically delete every rule whose app name starts with prefix", Write: true,
+		Query: []apiParam{{"prefix", "Required app name prefix"}}, Response: bulkDeleteResult{}, Status: 200},
+	{Method: "GET", Path: "/v1/rules/stream", Summary: "Server-sent events for every rule change", Response: RuleEvent{}, Status: 200, ContentType: "text/event-stream"},
+	{Method: "POST", Path: "/v1/rules/import", Summary: "Atomically import a rule array or export document", Write: true,
+		Request: RuleExport{}, Response: map[string]int{}, Status: 200},
+	{Method: "GET", Path: "/v1/rules/export", Summary: "Export every rule", Response: RuleExport{}, Status: 200},
+	{Method: "GET", Path: "/v1/rules/count", Summary: "Count stored rules", Response: countResponse{}, Status: 200},
+	{Method: "POST", Path: "/v1/rules/diff", Summary: "Compare two exported rule sets, or one against the live rules when to is omitted",
+		Request: diffRequest{}, Response: ruleDiff{}, Status: 200},
+	{Method: "GET", Path: "/v1/rules/search", Summary: "Find rules whose effective lists, templates and variables applied, match a domain and/or IP",
+		Query: []apiParam{{"domain", "Domain to match"}, {"ip", "Address to match"}}, Response: []FirewallRule{}, Status: 200},
+	{Method: "POST", Path: "/v1/logs", Summary: "Submit a network log; the response carries its server-assigned ID", Write: true, Request: NetworkLog{}, Response: logReceipt{}, Status: 201},
+	{Method: "POST", Path: "/v1/logs/batch", Summary: "Submit many network logs; invalid entries are reported", Write: true, Request: []NetworkLog{}, Response: logBatchResult{}, Status: 200},
+	{Method: "GET", Path: "/v1/logs", Summary: "Page through stored logs, newest first, or oldest first after since_id",
+		Query: []apiParam{{"app_name", "Filter by app"}, {"action", "Filter by action"}, {"severity", "Filter by severity"}, {"since", "Only logs newer than this duration, e.g. 1h"},
+			{"since_id", "Only logs with a greater ID"}, {"limit", "Page size, at most the log cap"}, {"offset", "Entries to skip"}},
+		Response: logPage{}, Status: 200},
+	{Method: "GET", Path: "/v1/logs/count", Summary: "Count stored logs matching the GET /v1/logs filters",
+		Query: []apiParam{{"app_name", "Filter by app"}, {"action", "Filter by action"}, {"severity", "Filter by severity"}, {"since", "Only logs newer than this duration, e.g. 1h"},
+			{"since_id", "Only logs with a greater ID"}},
+		Response: countResponse{}, Status: 200},
+	{Method: "GET", Path: "/v1/logs/export", Summary: "Download filtered logs as a JSON array or, with format=csv, as CSV",
+		Query: []apiParam{{"format", "json (default) or csv"}, {"app_name", "Filter by app"}, {"action", "Filter by action"},
+			{"severity", "Filter by severity"}, {"since", "Only logs newer than this duration, e.g. 1h"}, {"since_id", "Only logs with a greater ID"}},
+		Response: []NetworkLog{}, Status: 200},
+	{Method: "GET", Path: "/v1/logs/stream", Summary: "WebSocket carrying each new log as JSON",
+		Query: []apiParam{{"app_name", "Filter by app"}, {"action", "Filter by action"}, {"severity", "Filter by severity"}}, Response: NetworkLog{}, Status: 101},
+	{Method: "GET", Path: "/v1/logs/stats", Summary: "Aggregate counts over stored logs",
+		Query: []apiParam{{"since", "Only count logs newer than this duration, e.g. 1h"}}, Response: logStats{}, Status: 200},
+	{Method: "GET", Path: "/v1/audit", Summary: "List rule audit entries, newest first",
+		Query:    []apiParam{{"app_name", "Filter by app"}, {"since", "RFC 3339 lower bound"}, {"until", "RFC 3339 upper bound"}},
+		Response: []AuditEntry{}, Status: 200},
+	{Method: "POST", Path: "/v1/decision", Summary: "Decide a connection against an app's effective rule, falling back to the \"*\" default rule",
+		Request: decisionRequest{}, Response: decisionResponse{}, Status: 200},
+	{Method: "POST", Path: "/v1/simulate", Summary: "Replay stored logs through a candidate rule set and count outcomes that would flip or go undecided, by app",
+		Request: simulateRequest{}, Response: simulateResult{}, Status: 200},
+	{Method: "GET", Path: "/v1/admin/readonly", Summary: "Report whether rule changes are frozen", Response: readOnlyStatus{}, Status: 200},
+	{Method: "POST", Path: "/v1/admin/readonly", Summary: "Freeze or unfreeze rule changes; frozen writes get 503 READ_ONLY", Write: true,
+		Request: readOnlyStatus{}, Response: readOnlyStatus{}, Status: 200},
+	{Method: "POST", Path: "/v1/admin/reload", Summary: "Re-read the config file, applying auth tokens, CORS origins, log rate limits and retention live",
+		Write: true, Response: reloadResult{}, Status: 200},
+	{Method: "GET", Path: "/v1/agents", Summary: "List registered agents", Response: []Agent{}, Status: 200},
+	{Method: "POST", Path: "/v1/agents/register", Summary: "Register or re-register an agent", Write: true, Request: Agent{}, Status: 201},
+	{Method: "POST", Path: "/v1/agents/{id}/heartbeat", Summary: "Mark an agent as alive, optionally updating the apps it enforces", Write: true, Request: heartbeatRequest{}, Status: 204},
+	{Method: "GET", Path: "/v1/agents/{id}/policy", Summary: "Get the effective rules for an agent's apps, the default rule and a hash of them", Response: agentPolicy{}, Status: 200},
+}
+
+var pathParamRE = regexp.MustCompile(`\{([^}]+)\}`)
+
+var (
+	timeType   = reflect.TypeOf(time.Time{})
+	marshaler  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	enumValues = map[reflect.Type][]string{
+		reflect.TypeOf(Decision("")): {string(Allow), string(Block), string(NoMatch)},
+		reflect.TypeOf(Protocol("")): {string(ProtocolTCP), string(ProtocolUDP), string(ProtocolICMP)},
+		reflect.TypeOf(Severity("")): {string(SeverityInfo), string(SeverityLow), string(SeverityMedium), string(SeverityHigh)},
+		reflect.TypeOf(ErrorCode("")): func() (codes []string) {
+			for _, c := range errorCodes {
+				codes = append(codes, string(c))
+			}
+			return
+		}(),
+	}
+)
+
+// schemaSet collects the named object schemas referenced by operations.
+type schemaSet map[string]any
+
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+func (set schemaSet) schemaFor(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	if values, ok := enumValues[t]; ok {
+		return map[string]any{"type": "string", "enum": values}
+	}
+	// Types with their own JSON form, such as PortRange, encode as strings.
+	if t.Implements(marshaler) || reflect.PointerTo(t).Implements(marshaler) {
+		return map[string]any{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": set.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": set.schemaFor(t.Elem())}
+	case reflect.Struct:
+		name := schemaName(t)
+		if _, ok := set[name]; !ok {
+			set[name] = nil // guards against recursion while the fields are built
+			properties := map[string]any{}
+			set.addFields(t, properties)
+			set[name] = map[string]any{"type": "object", "properties": properties}
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+// addFields adds t's JSON fields to properties, flattening embedded structs.
+func (set schemaSet) addFields(t reflect.Type, properties map[string]any) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			set.addFields(f.Type, properties)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = set.schemaFor(f.Type)
+	}
+}
+
+func (op apiOperation) build(set schemaSet) map[string]any {
+	contentType := op.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	var params []any
+	for _, m := range pathParamRE.FindAllStringSubmatch(op.Path, -1) {
+		params = append(params, map[string]any{"name": m[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"}})
+	}
+	for _, q := range op.Query {
+		params = append(params, map[string]any{"name": q.Name, "in": "query", "description": q.Description, "schema": map[string]any{"type": "string"}})
+	}
+
+	response := func(status int, body any) map[string]any {
+		out := map[string]any{"description": http.StatusText(status)}
+		if body != nil {
+			out["content"] = map[string]any{contentType: map[string]any{"schema": set.schemaFor(reflect.TypeOf(body))}}
+		} else if op.ContentType != "" {
+			out["content"] = map[string]any{contentType: map[string]any{"schema": map[string]any{"type": "string"}}}
+		}
+		return out
+	}
+	responses := map[string]any{strconv.Itoa(op.Status): response(op.Status, op.Response)}
+	for status, body := range op.Also {
+		responses[strconv.Itoa(status)] = response(status, body)
+	}
+	responses["default"] = map[string]any{
+		"description": "Error",
+		"content":     map[string]any{"application/json": map[string]any{"schema": set.schemaFor(reflect.TypeOf(APIError{}))}},
+	}
+	doc := map[string]any{
+		"summary":   op.Summary,
+		"responses": responses,
+	}
+	if len(params) > 0 {
+		doc["parameters"] = params
+	}
+	if op.Request != nil {
+		doc["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": set.schemaFor(reflect.TypeOf(op.Request))}},
+		}
+	}
+	if op.Write {
+		doc["security"] = []any{map[string]any{"bearerAuth": []string{}}}
+	}
+	return doc
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  []byte
+)
+
+func buildOpenAPI() []byte {
+	set := schemaSet{}
+	paths := map[string]map[string]any{}
+	for _, op := range apiOperations {
+		if paths[op.Path] == nil {
+			paths[op.Path] = map[string]any{}
+		}
+		paths[op.Path][strings.ToLower(op.Method)] = op.build(set)
+	}
+	doc := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Firewall central server",
+			"version":     "1",
+			"description": "Write operations need a bearer token; reads do too when the server requires authenticated reads. Unprefixed paths are deprecated aliases of /v1.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas":         set,
+			"securitySchemes": map[string]any{"bearerAuth": map[string]any{"type": "http", "scheme": "bearer"}},
+		},
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (s *CentralServer) HandleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	openAPIOnce.Do(func() { openAPIDoc = buildOpenAPI() })
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDoc)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// TestOpenAPIDocumentsEveryRoute keeps apiOperations in step with the
+// router. Unprefixed aliases of documented /v1 routes don't count.
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	documented := map[string]bool{}
+	for _, op := range apiOperations {
+		documented[op.Method+" "+op.Path] = true
+	}
+	registered := map[string]bool{}
+	s := newTestServer(t)
+	s.Routes().Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, _ := route.GetMethods()
+		for _, m := range methods {
+			registered[m+" "+path] = true
+			if !documented[m+" "+path] && !documented[m+" /v1"+path] {
+				t.Errorf("%s %s is missing from apiOperations", m, path)
+			}
+		}
+		return nil
+	})
+	for route := range documented {
+		if !registered[route] {
+			t.Errorf("apiOperations documents %s, which is not routed", route)
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	rec := call(t, newTestServer(t).Handler(), "GET", "/openapi.json", nil)
+	expect(t, rec, http.StatusOK)
+	var doc struct {
+		OpenAPI string                    `json:"openapi"`
+		Paths   map[string]map[string]any `json:"paths"`
+	}
+	decode(t, rec, &doc)
+	if doc.OpenAPI == "" || doc.Paths["/v1/rule/{app_name}"]["get"] == nil {
+		t.Errorf("document lacks the openapi version or GET /v1/rule/{app_name}: %d paths", len(doc.Paths))
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	_ "github.com/lib/pq"
+)
+
+// postgresMigrations are applied in order; the schema_version table records
+// how many have run.
+var postgresMigrations = []string{
+	`CREATE TABLE rules (
+		app_name          TEXT PRIMARY KEY,
+		allowed_domains   JSONB NOT NULL DEFAULT '[]',
+		allowed_ips       JSONB NOT NULL DEFAULT '[]',
+		allowed_protocols JSONB NOT NULL DEFAULT '[]',
+		blocked_domains   JSONB NOT NULL DEFAULT '[]',
+		blocked_ips       JSONB NOT NULL DEFAULT '[]',
+		blocked_ports     JSONB NOT NULL DEFAULT '[]',
+		allowed_ports     JSONB NOT NULL DEFAULT '[]',
+		schedule          JSONB NOT NULL DEFAULT 'null',
+		version           INTEGER NOT NULL DEFAULT 0,
+		updated_at        TIMESTAMPTZ NOT NULL DEFAULT 'epoch'
+	)`,
+	`CREATE TABLE audit (
+		id        BIGSERIAL PRIMARY KEY,
+		timestamp TIMESTAMPTZ NOT NULL,
+		actor     TEXT NOT NULL,
+		action    TEXT NOT NULL,
+		app_name  TEXT NOT NULL,
+		before    JSONB,
+		after     JSONB
+	)`,
+	`CREATE INDEX audit_app_name ON audit (app_name, timestamp)`,
+	`CREATE TABLE rule_history (
+		app_name TEXT NOT NULL,
+		version  INTEGER NOT NULL,
+		rule     JSONB NOT NULL,
+		PRIMARY KEY (app_name, version)
+	)`,
+	`ALTER TABLE rules ADD COLUMN default_action TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE rules ADD COLUMN templates JSONB NOT NULL DEFAULT '[]'`,
+	`ALTER TABLE rules ADD COLUMN template BOOLEAN NOT NULL DEFAULT false`,
+	`ALTER TABLE rules ADD COLUMN enabled BOOLEAN NOT NULL DEFAULT true`,
+	`CREATE TABLE settings (
+		name  TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	)`,
+	`CREATE TABLE logs (
+		id            BIGSERIAL PRIMARY KEY,
+		app_name      TEXT NOT NULL,
+		remote_ip     TEXT NOT NULL,
+		remote_domain TEXT NOT NULL,
+		protocol      TEXT NOT NULL,
+		port          INTEGER NOT NULL,
+		action        TEXT NOT NULL,
+		timestamp     TIMESTAMPTZ NOT NULL,
+		country       TEXT NOT NULL DEFAULT '',
+		asn           BIGINT NOT NULL DEFAULT 0,
+		severity      TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE INDEX logs_app_name ON logs (app_name, timestamp)`,
+}
+
+// postgresMigrationLock is the advisory lock key replicas hold while
+// migrating, so several starting at once don't race on the schema.
+const postgresMigrationLock = 0x6677_7275_6c65
+
+// PostgresRuleStore keeps rules in a Postgres database so several server
+// replicas can share them. Each replica serialises its own writes; between
+// replicas the conditional upsert makes the slower of two writers to the same
+// rule fail with ErrVersionConflict. It also archives logs for LogArchive.
+type PostgresRuleStore struct {
+	db *sql.DB
+}
+
+var pgRuleUpsertSQL = buildRuleUpsertSQL(func(n int) string { return fmt.Sprintf("$%d", n) })
+
+func NewPostgresRuleStore(dsn string) (*PostgresRuleStore, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &PostgresRuleStore{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func openPostgresReadOnly(dsn string) (*PostgresRuleStore, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	var version int
+	if err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if version < len(postgresMigrations) {
+		db.Close()
+		return nil, errSchemaOutdated(version, len(postgresMigrations))
+	}
+	return &PostgresRuleStore{db: db}, nil
+}
+
+func (s *PostgresRuleStore) migrate() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", postgresMigrationLock); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)"); err != nil {
+		return err
+	}
+	var version int
+	if err := tx.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version); err != nil {
+		return err
+	}
+	if version >= len(postgresMigrations) {
+		return nil
+	}
+	for i := version; i < len(postgresMigrations); i++ {
+		if _, err := tx.Exec(postgresMigrations[i]); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM schema_version"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO schema_version (version) VALUES ($1)", len(postgresMigrations)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresRuleStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *PostgresRuleStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *PostgresRuleStore) Get(ctx context.Context, appName string) (FirewallRule, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+ruleColumns+" FROM rules WHERE app_name = $1", appName)
+	rule, err := scanRule(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rule, ErrRuleNotFound
+	}
+	return rule, err
+}
+
+func (s *PostgresRuleStore) Set(ctx context.Context, rule FirewallRule) error {
+	return upsertRule(ctx, s.db, pgRuleUpsertSQL, rule)
+}
+
+func (s *PostgresRuleStore) SetAll(ctx context.Context, rules []FirewallRule) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, rule := range rules {
+		if err := upsertRule(ctx, tx, pgRuleUpsertSQL, rule); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresRuleStore) Delete(ctx context.Context, appName string) error {
+	return s.DeleteAll(ctx, []string{appName})
+}
+
+func (s *PostgresRuleStore) DeleteAll(ctx context.Context, appNames []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, appName := range appNames {
+		res, err := tx.ExecContext(ctx, "DELETE FROM rules WHERE app_name = $1", appName)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("%w: %s", ErrRuleNotFound, appName)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM rule_history WHERE app_name = $1", appName); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresRuleStore) List(ctx context.Context) ([]FirewallRule, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+ruleColumns+" FROM rules ORDER BY app_name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []FirewallRule{}
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (s *PostgresRuleStore) AppendHistory(ctx context.Context, rule FirewallRule, keep int) error {
+	encoded, err := json.Marshal(rule)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO rule_history (app_name, version, rule) VALUES ($1, $2, $3)
+		ON CONFLICT (app_name, version) DO UPDATE SET rule = excluded.rule`,
+		rule.AppName, rule.Version, string(encoded)); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM rule_history WHERE app_name = $1 AND version NOT IN (
+		SELECT version FROM rule_history WHERE app_name = $1 ORDER BY version DESC LIMIT $2)`,
+		rule.AppName, keep); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresRuleStore) Setting(ctx context.Context, name string) (string, error) {
+	var value string
+	err := s.db.QueryRowContext(ctx, "SELECT value FROM settings WHERE name = $1", name).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return value, err
+}
+
+func (s *PostgresRuleStore) PutSetting(ctx context.Context, name, value string) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO settings (name, value) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET value = excluded.value`, name, value)
+	return err
+}
+
+func (s *PostgresRuleStore) History(ctx context.Context, appName string) ([]FirewallRule, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT rule FROM rule_history WHERE app_name = $1 ORDER BY version DESC", appName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []FirewallRule{}
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		var rule FirewallRule
+		if err := json.Unmarshal([]byte(raw), &rule); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rule)
+	}
+	return revisions, rows.Err()
+}
+
+func (s *PostgresRuleStore) AppendAudit(ctx context.Context, entry AuditEntry) error {
+	before, err := encodeAuditRule(entry.Before)
+	if err != nil {
+		return err
+	}
+	after, err := encodeAuditRule(entry.After)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, "INSERT INTO audit (timestamp, actor, action, app_name, before, after) VALUES ($1, $2, $3, $4, $5, $6)",
+		entry.Timestamp, entry.Actor, entry.Action, entry.AppName, before, after)
+	return err
+}
+
+func (s *PostgresRuleStore) ListAudit(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	query := "SELECT timestamp, actor, action, app_name, before, after FROM audit WHERE 1 = 1"
+	var args []any
+	if filter.AppName != "" {
+		args = append(args, filter.AppName)
+		query += fmt.Sprintf(" AND app_name = $%d", len(args))
+	}
+	if !filter.Since.IsZero() {
+		args = append(args, filter.Since)
+		query += fmt.Sprintf(" AND timestamp >= $%d", len(args))
+	}
+	if !filter.Until.IsZero() {
+		args = append(args, filter.Until)
+		query += fmt.Sprintf(" AND timestamp <= $%d", len(args))
+	}
+	query += " ORDER BY id DESC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var before, after sql.NullString
+		if err := rows.Scan(&entry.Timestamp, &entry.Actor, &entry.Action, &entry.AppName, &before, &after); err != nil {
+			return nil, err
+		}
+		if entry.Before, err = decodeAuditRule(before); err != nil {
+			return nil, err
+		}
+		if entry.After, err = decodeAuditRule(after); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// ArchiveLogs inserts logs in one transaction. Their buffer IDs are not kept:
+// each replica numbers its own logs, so the table assigns its own.
+func (s *PostgresRuleStore) ArchiveLogs(ctx context.Context, logs []NetworkLog) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO logs (app_name, remote_ip, remote_domain, protocol, port, action, timestamp, country, asn, severity)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, l := range logs {
+		if _, err := stmt.ExecContext(ctx, l.AppName, l.RemoteIP, l.RemoteDomain, l.Protocol, l.Port, l.Action,
+			l.Timestamp, l.Country, int64(l.ASN), string(l.Severity)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,163 @@
+//go:build postgres
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// openTestPostgres returns a store in a fresh schema of the database at
+// DATABASE_URL, dropped when the test ends. Run with:
+//
+//	DATABASE_URL=postgres://... go test -tags postgres -run Postgres
+func openTestPostgres(t *testing.T) *PostgresRuleStore {
+	t.Helper()
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	schema := fmt.Sprintf("firewall_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db, err := sql.Open("postgres", dsn)
+		if err == nil {
+			db.Exec("DROP SCHEMA " + schema + " CASCADE")
+			db.Close()
+		}
+	})
+
+	if strings.Contains(dsn, "://") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + "search_path=" + schema
+	} else {
+		dsn += " search_path=" + schema
+	}
+	store, err := NewPostgresRuleStore(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestPostgresRuleStore(t *testing.T) {
+	store := openTestPostgres(t)
+	ctx := context.Background()
+
+	rule := FirewallRule{
+		AppName:        "curl",
+		AllowedDomains: []string{"*.example.com"},
+		AllowedPorts:   []PortRange{{443, 443}},
+		Schedule:       &Schedule{StartTime: "09:00", EndTime: "17:00"},
+		DefaultAction:  Block,
+		Enabled:        true,
+		Version:        1,
+		UpdatedAt:      time.Now().UTC().Truncate(time.Microsecond),
+	}
+	if err := store.Set(ctx, rule); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Get(ctx, "curl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AllowedDomains[0] != "*.example.com" || got.AllowedPorts[0] != (PortRange{443, 443}) || got.Schedule == nil ||
+		!got.UpdatedAt.Equal(rule.UpdatedAt) || got.Version != 1 {
+		t.Errorf("Get = %+v, want %+v", got, rule)
+	}
+
+	if err := store.AppendHistory(ctx, rule, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AppendAudit(ctx, AuditEntry{Timestamp: time.Now().UTC(), Actor: "admin", Action: AuditCreate, AppName: "curl", After: &rule}); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := store.ListAudit(ctx, AuditFilter{AppName: "curl"}); err != nil || len(entries) != 1 || entries[0].After == nil {
+		t.Errorf("ListAudit = %+v, %v", entries, err)
+	}
+
+	if err := store.Delete(ctx, "curl"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "curl"); !errors.Is(err, ErrRuleNotFound) {
+		t.Errorf("Get after Delete: %v", err)
+	}
+	if revisions, err := store.History(ctx, "curl"); err != nil || len(revisions) != 0 {
+		t.Errorf("History after Delete = %d revisions, %v", len(revisions), err)
+	}
+}
+
+func TestPostgresConcurrentWriters(t *testing.T) {
+	store := openTestPostgres(t)
+	ctx := context.Background()
+	if err := store.Set(ctx, FirewallRule{AppName: "curl", Version: 1, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each writer stands in for a replica that read version 1.
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make([]error, writers)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = store.Set(ctx, FirewallRule{AppName: "curl", AllowedDomains: []string{fmt.Sprintf("w%d.example", i)}, Version: 2, Enabled: true})
+		}(i)
+	}
+	wg.Wait()
+
+	won := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			won++
+		case !errors.Is(err, ErrVersionConflict):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if won != 1 {
+		t.Errorf("%d writers won, want exactly 1", won)
+	}
+}
+
+func TestPostgresArchiveLogs(t *testing.T) {
+	store := openTestPostgres(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	logs := []NetworkLog{
+		{ID: 1, AppName: "curl", RemoteIP: "10.0.0.1", Protocol: "tcp", Port: 443, Action: ActionAllowed, Timestamp: now},
+		{ID: 2, AppName: "curl", RemoteDomain: "ads.example", Protocol: "tcp", Port: 80, Action: ActionBlocked, Timestamp: now, Severity: SeverityHigh},
+	}
+	if err := store.ArchiveLogs(ctx, logs); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ArchiveLogs(ctx, logs[:1]); err != nil {
+		t.Fatal(err)
+	}
+	var total, blocked int
+	if err := store.db.QueryRow("SELECT COUNT(*), COUNT(*) FILTER (WHERE action = 'blocked') FROM logs").Scan(&total, &blocked); err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || blocked != 1 {
+		t.Errorf("archived %d logs (%d blocked), want 3 (1 blocked)", total, blocked)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestLogRateLimit(t *testing.T) {
+	s := newTestServer(t)
+	s.logLimiter.SetLimit(1, 2)
+	h := s.Handler()
+	entry := NetworkLog{AppName: "curl", Action: ActionAllowed}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		want       int
+		retryAfter string
+	}{
+		{"first in burst", "192.0.2.1:1000", http.StatusCreated, ""},
+		{"second in burst", "192.0.2.1:1001", http.StatusCreated, ""},
+		{"burst spent", "192.0.2.1:1002", http.StatusTooManyRequests, "1"},
+		{"other client", "192.0.2.2:1000", http.StatusCreated, ""},
+	}
+	for _, tt := range tests {
+		req := newRequest(t, "POST", "/v1/logs", entry)
+		req.RemoteAddr = tt.remoteAddr
+		rec := serve(h, req)
+		if rec.Code != tt.want || rec.Header().Get("Retry-After") != tt.retryAfter {
+			t.Errorf("%s: status %d, Retry-After %q; want %d, %q", tt.name, rec.Code, rec.Header().Get("Retry-After"), tt.want, tt.retryAfter)
+		}
+	}
+
+	// Only log submission is limited; the spent client can still read.
+	expect(t, call(t, h, "GET", "/v1/logs", nil), http.StatusOK)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// ErrReadOnly is returned by rule writes while the server is in read-only
+// mode.
+var ErrReadOnly = errors.New("server is in read-only mode")
+
+// readOnlySetting is the store setting that persists the mode across
+// restarts.
+const readOnlySetting = "read_only"
+
+type readOnlyStatus struct {
+	Enabled bool `json:"enabled"`
+}
+
+// LoadReadOnly restores the mode saved by SetReadOnly.
+func (s *CentralServer) LoadReadOnly(ctx context.Context) error {
+	value, err := s.store.Setting(ctx, readOnlySetting)
+	if err != nil || value == "" {
+		return err
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.readOnly = enabled
+	s.mu.Unlock()
+	return nil
+}
+
+// SetReadOnly freezes or unfreezes rule changes and saves the mode. Writes
+// already holding s.mu finish first. Logs, agents and every read carry on.
+func (s *CentralServer) SetReadOnly(ctx context.Context, enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.store.PutSetting(ctx, readOnlySetting, strconv.FormatBool(enabled)); err != nil {
+		return err
+	}
+	s.readOnly = enabled
+	return nil
+}
+
+// checkWritableLocked returns ErrReadOnly while rule changes are frozen.
+// Callers hold s.mu.
+func (s *CentralServer) checkWritableLocked() error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+func (s *CentralServer) HandleGetReadOnly(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	status := readOnlyStatus{Enabled: s.readOnly}
+	s.mu.RUnlock()
+	json.NewEncoder(w).Encode(status)
+}
+
+func (s *CentralServer) HandleSetReadOnly(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if req.Enabled == nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "enabled is required")
+		return
+	}
+	if err := s.SetReadOnly(r.Context(), *req.Enabled); err != nil {
+		writeError(w, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+	requestLogger(r).Info("read-only mode changed", "enabled", *req.Enabled, "actor", Actor(r.Context()))
+	json.NewEncoder(w).Encode(readOnlyStatus{Enabled: *req.Enabled})
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestReadOnlyMode(t *testing.T) {
+	s := newTestServer(t)
+	h := s.Handler()
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"example.com"}, Enabled: true})
+
+	rec := call(t, h, "POST", "/v1/admin/readonly", `{"enabled": true}`)
+	expect(t, rec, http.StatusOK)
+
+	rec = call(t, h, "POST", "/v1/rule", FirewallRule{AppName: "curl", AllowedDomains: []string{"example.org"}, Enabled: true})
+	expect(t, rec, http.StatusServiceUnavailable)
+	var apiErr APIError
+	if decode(t, rec, &apiErr); apiErr.Code != CodeReadOnly {
+		t.Errorf("error = %+v, want %s", apiErr, CodeReadOnly)
+	}
+	expect(t, call(t, h, "DELETE", "/v1/rule/curl", nil), http.StatusServiceUnavailable)
+	expect(t, call(t, h, "POST", "/v1/rule/curl/disable", nil), http.StatusServiceUnavailable)
+	// Enabling the already enabled rule would change nothing, but is still a write.
+	expect(t, call(t, h, "POST", "/v1/rule/curl/enable", nil), http.StatusServiceUnavailable)
+
+	rec = call(t, h, "GET", "/v1/rule/curl", nil)
+	expect(t, rec, http.StatusOK)
+	var rule FirewallRule
+	if decode(t, rec, &rule); rule.Version != 1 || rule.AllowedDomains[0] != "example.com" {
+		t.Errorf("rule = %+v, want it unchanged", rule)
+	}
+	expect(t, call(t, h, "POST", "/v1/logs", NetworkLog{AppName: "curl", RemoteIP: "10.0.0.1", Action: ActionAllowed}), http.StatusCreated)
+
+	// The mode is saved, so a restart on the same store comes back frozen.
+	restarted := NewCentralServer(s.store)
+	if err := restarted.LoadReadOnly(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var status readOnlyStatus
+	decode(t, call(t, restarted.Handler(), "GET", "/v1/admin/readonly", nil), &status)
+	if !status.Enabled {
+		t.Error("read-only mode was lost on restart")
+	}
+
+	expect(t, call(t, h, "POST", "/v1/admin/readonly", `{"enabled": false}`), http.StatusOK)
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"example.org"}, Enabled: true})
+}
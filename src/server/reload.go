@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// hotSettings are the Config fields, by JSON name, that Reload applies to a
+// running server. Changes to any other field wait for a restart.
+var hotSettings = []string{"auth_token", "auth_tokens", "cors_origins", "log_rate_limit", "log_rate_burst", "log_retention"}
+
+// errInvalidConfig wraps every reason the config could not be loaded again:
+// a missing or unreadable file, malformed JSON or environment, or a failed
+// Validate.
+var errInvalidConfig = errors.New("invalid config")
+
+// reloadResult names the settings that differed from the running config:
+// those now in effect and those that only a restart will pick up.
+type reloadResult struct {
+	Applied         []string `json:"applied"`
+	RestartRequired []string `json:"restart_required"`
+}
+
+// Reload loads the config again, file then environment as at startup, and
+// swaps in the hot settings that changed. An invalid config changes nothing.
+// Streams and in-flight requests carry on, authenticated as they were.
+func (s *CentralServer) Reload() (reloadResult, error) {
+	cfg, err := LoadConfig(s.configPath)
+	if err != nil {
+		return reloadResult{}, fmt.Errorf("%w: %v", errInvalidConfig, err)
+	}
+
+	s.liveMu.Lock()
+	defer s.liveMu.Unlock()
+	result := reloadResult{Applied: []string{}, RestartRequired: []string{}}
+	running, loaded := reflect.ValueOf(&s.config).Elem(), reflect.ValueOf(cfg)
+	for i := 0; i < running.NumField(); i++ {
+		name, _, _ := strings.Cut(running.Type().Field(i).Tag.Get("json"), ",")
+		if reflect.DeepEqual(running.Field(i).Interface(), loaded.Field(i).Interface()) {
+			continue
+		}
+		if slices.Contains(hotSettings, name) {
+			running.Field(i).Set(loaded.Field(i))
+			result.Applied = append(result.Applied, name)
+		} else {
+			result.RestartRequired = append(result.RestartRequired, name)
+		}
+	}
+
+	c := s.config
+	s.AuthToken = c.AuthToken
+	s.AuthTokens = c.AuthTokens
+	s.CORSOrigins = c.CORSOrigins
+	s.LogRetention = time.Duration(c.LogRetention)
+	s.logLimiter.SetLimit(c.LogRateLimit, c.LogRateBurst)
+	return result, nil
+}
+
+func (s *CentralServer) HandleReload(w http.ResponseWriter, r *http.Request) {
+	result, err := s.Reload()
+	if errors.Is(err, errInvalidConfig) {
+		writeError(w, http.StatusUnprocessableEntity, CodeValidationFailed, "Config not reloaded: "+err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, CodeInternal, "Config not reloaded: "+err.Error())
+		return
+	}
+	requestLogger(r).Info("config reloaded", "applied", result.Applied, "restart_required", result.RestartRequired, "actor", Actor(r.Context()))
+	json.NewEncoder(w).Encode(result)
+}
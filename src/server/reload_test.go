@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReloadAuthToken(t *testing.T) {
+	s := newTestServer(t)
+	path := writeConfig(t, `{"auth_token": "old-token"}`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Apply(s)
+	s.configPath = path
+	h := s.Handler()
+	as := func(token, method, path string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+		req := newRequest(t, method, path, body)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return serve(h, req)
+	}
+	rule := FirewallRule{AppName: "curl", AllowedDomains: []string{"example.com"}, Enabled: true}
+
+	if err := os.WriteFile(path, []byte(`{"auth_token": "new-token", "addr": ":7000"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rec := as("old-token", "POST", "/v1/admin/reload", nil)
+	expect(t, rec, http.StatusOK)
+	var result reloadResult
+	decode(t, rec, &result)
+	if !slices.Equal(result.Applied, []string{"auth_token"}) || !slices.Equal(result.RestartRequired, []string{"addr"}) {
+		t.Errorf("reload = %+v, want auth_token applied and addr left for a restart", result)
+	}
+	expect(t, as("old-token", "POST", "/v1/rule", rule), http.StatusUnauthorized)
+	expect(t, as("new-token", "POST", "/v1/rule", rule), http.StatusCreated)
+
+	// A config that can't be loaded is reported as such and changes nothing.
+	tests := []struct {
+		name string
+		body string // empty removes the file
+		want string
+	}{
+		{"invalid", `{"auth_token": "bad-token", "addr": ""}`, "addr must not be empty"},
+		{"malformed", `{"auth_token": `, "parsing"},
+		{"missing", "", "config.json"},
+	}
+	for _, tt := range tests {
+		if tt.body == "" {
+			os.Remove(path)
+		} else if err := os.WriteFile(path, []byte(tt.body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		rec := as("new-token", "POST", "/v1/admin/reload", nil)
+		var apiErr APIError
+		decode(t, rec, &apiErr)
+		if rec.Code != http.StatusUnprocessableEntity || apiErr.Code != CodeValidationFailed || !strings.Contains(apiErr.Message, tt.want) {
+			t.Errorf("%s config: %d %+v, want 422 VALIDATION_FAILED mentioning %q", tt.name, rec.Code, apiErr, tt.want)
+		}
+	}
+	expect(t, as("new-token", "POST", "/v1/rule", rule), http.StatusCreated)
+	expect(t, as("bad-token", "POST", "/v1/rule", rule), http.StatusUnauthorized)
+}
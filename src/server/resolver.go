@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolver looks up a host's addresses. *net.Resolver satisfies it; tests
+// can substitute a fake.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+const (
+	defaultDNSCacheTTL = 5 * time.Minute
+	dnsLookupTimeout   = 2 * time.Second
+)
+
+type dnsEntry struct {
+	addrs    []net.IP
+	expires  time.Time
+	lastUsed time.Time
+}
+
+// DNSCache maps allowed domains to their addresses so a connection seen only
+// by IP can still match a domain rule. Evaluation only ever reads it: a host
+// seen for the first time is resolved in the background and matches nothing
+// until its answer arrives. The system resolver doesn't report record TTLs,
+// so every answer is kept for a fixed ttl. Expired entries keep being served
+// until the background refresh replaces them, and a failed lookup keeps the
+// previous answer.
+type DNSCache struct {
+	resolver Resolver
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*dnsEntry
+}
+
+func NewDNSCache(resolver Resolver, ttl time.Duration) *DNSCache {
+	return &DNSCache{resolver: resolver, ttl: ttl, entries: make(map[string]*dnsEntry)}
+}
+
+// Addrs returns host's cached addresses without waiting on DNS. On a miss it
+// starts resolving host and returns nil.
+func (c *DNSCache) Addrs(host string) []net.IP {
+	host = normalizeDomain(host)
+	if host == "" || strings.HasPrefix(host, "*.") {
+		return nil
+	}
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[host]; ok {
+		e.lastUsed = now
+		return e.addrs
+	}
+	// The placeholder stops later misses, and Start, from resolving host
+	// again while this lookup runs.
+	c.entries[host] = &dnsEntry{expires: now.Add(dnsLookupTimeout), lastUsed: now}
+	go c.refresh(context.Background(), host)
+	return nil
+}
+
+func (c *DNSCache) refresh(ctx context.Context, host string) {
+	ctx, cancel := context.WithTimeout(ctx, dnsLookupTimeout)
+	defer cancel()
+	found, err := c.resolver.LookupIPAddr(ctx, host)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[host]
+	if !ok {
+		e = &dnsEntry{lastUsed: time.Now()}
+		c.entries[host] = e
+	}
+	e.expires = time.Now().Add(c.ttl)
+	if err != nil {
+		contextLogger(ctx).Debug("resolving rule domain", "host", host, "err", err)
+		return
+	}
+	e.addrs = make([]net.IP, len(found))
+	for i, addr := range found {
+		e.addrs[i] = addr.IP
+	}
+}
+
+// Start re-resolves expired entries until ctx is done, forgetting hosts that
+// no evaluation has asked about for ten TTLs.
+func (c *DNSCache) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(max(c.ttl/2, time.Second))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				for _, host := range c.due(now) {
+					c.refresh(ctx, host)
+				}
+			}
+		}
+	}()
+}
+
+// due drops idle entries and returns the hosts whose answers have expired.
+func (c *DNSCache) due(now time.Time) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var hosts []string
+	for host, e := range c.entries {
+		switch {
+		case now.Sub(e.lastUsed) > 10*c.ttl:
+			delete(c.entries, host)
+		case now.After(e.expires):
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedResolver answers from addrs once release is closed, counting lookups.
+type gatedResolver struct {
+	addrs   map[string][]net.IPAddr
+	release chan struct{}
+
+	mu      sync.Mutex
+	lookups int
+}
+
+func (r *gatedResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	r.mu.Lock()
+	r.lookups++
+	r.mu.Unlock()
+	select {
+	case <-r.release:
+		return r.addrs[host], nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (r *gatedResolver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lookups
+}
+
+// waitForAddrs polls the cache until host resolves.
+func waitForAddrs(t *testing.T, c *DNSCache, host string) []net.IP {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if addrs := c.Addrs(host); len(addrs) > 0 {
+			return addrs
+		}
+	}
+	t.Fatalf("%s never resolved", host)
+	return nil
+}
+
+func TestDNSCacheNeverBlocks(t *testing.T) {
+	resolver := &gatedResolver{
+		addrs:   map[string][]net.IPAddr{"api.example.com": {{IP: net.ParseIP("192.0.2.10")}}},
+		release: make(chan struct{}),
+	}
+	c := NewDNSCache(resolver, time.Minute)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if addrs := c.Addrs("API.example.com."); addrs != nil {
+			t.Fatalf("miss returned %v before the lookup finished", addrs)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > dnsLookupTimeout/2 {
+		t.Errorf("misses took %s; Addrs waited on the resolver", elapsed)
+	}
+	if c.Addrs("*.example.com") != nil {
+		t.Error("wildcard resolved")
+	}
+
+	close(resolver.release)
+	addrs := waitForAddrs(t, c, "api.example.com")
+	if len(addrs) != 1 || !addrs[0].Equal(net.ParseIP("192.0.2.10")) {
+		t.Errorf("Addrs = %v", addrs)
+	}
+	if n := resolver.count(); n != 1 {
+		t.Errorf("%d lookups for one host, want 1", n)
+	}
+}
+
+func TestIPMatchesResolvedDomain(t *testing.T) {
+	s := newTestServer(t)
+	resolver := &gatedResolver{
+		addrs:   map[string][]net.IPAddr{"api.example.com": {{IP: net.ParseIP("192.0.2.10")}}},
+		release: make(chan struct{}),
+	}
+	s.DNS = NewDNSCache(resolver, time.Minute)
+	h := s.Handler()
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"api.example.com"}, Enabled: true})
+
+	evaluate := func() Decision {
+		rec := call(t, h, "POST", "/v1/rule/curl/evaluate", evaluateRequest{IP: "192.0.2.10", Port: 443, Protocol: "tcp"})
+		expect(t, rec, http.StatusOK)
+		var got evaluateResponse
+		decode(t, rec, &got)
+		return got.Decision
+	}
+	if got := evaluate(); got != Block {
+		t.Errorf("before the domain resolved: %s, want block", got)
+	}
+	close(resolver.release)
+	waitForAddrs(t, s.DNS, "api.example.com")
+	if got := evaluate(); got != Allow {
+		t.Errorf("after the domain resolved: %s, want allow", got)
+	}
+}
@@ -0,0 +1,497 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+type FirewallRule struct {
+	AppName          string      `json:"app_name"`
+	AllowedDomains   []string    `json:"allowed_domains"`
+	AllowedIPs       []string    `json:"allowed_ips"`
+	AllowedProtocols []Protocol  `json:"allowed_protocols"`
+	AllowedPorts     []PortRange `json:"allowed_ports,omitempty"`
+	BlockedDomains   []string    `json:"blocked_domains,omitempty"`
+	BlockedIPs       []string    `json:"blocked_ips,omitempty"`
+	BlockedPorts     []int       `json:"blocked_ports,omitempty"`
+
+	// Schedule, when set, restricts the allow-lists to a time window. Blocks
+	// apply at all times.
+	Schedule *Schedule `json:"schedule,omitempty"`
+
+	// DefaultAction (Allow or Block) decides connections nothing else
+	// matched. New rules get Block when it is left out and updates keep the
+	// stored value; rules saved before it existed have none and still return
+	// NoMatch.
+	DefaultAction Decision `json:"default_action,omitempty"`
+
+	// Templates names template rules whose allow and block lists this rule
+	// inherits at evaluation time. Template marks a rule as one; it is still
+	// stored and served like any other rule.
+	Templates []string `json:"templates,omitempty"`
+	Template  bool     `json:"template,omitempty"`
+
+	// Enabled is true unless the rule has been switched off. A disabled rule
+	// is kept, history and all, but lookups and evaluation treat it as absent.
+	Enabled bool `json:"enabled"`
+
+	// Version is bumped on every write. A client that sends a non-zero Version
+	// must match the stored one or its update is rejected as a conflict.
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UnmarshalJSON defaults Enabled to true, so clients that leave it out and
+// revisions stored before it existed describe enabled rules.
+func (r *FirewallRule) UnmarshalJSON(data []byte) error {
+	type plain FirewallRule
+	p := plain{Enabled: true}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*r = FirewallRule(p)
+	return nil
+}
+
+// PortRange is an inclusive port interval. On the wire it is a string holding
+// either a single port ("443") or a range ("8000-8100").
+type PortRange struct {
+	Low  int
+	High int
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return port, nil
+}
+
+func ParsePortRange(s string) (PortRange, error) {
+	low, high, isRange := strings.Cut(s, "-")
+	lo, err := parsePort(low)
+	if err != nil {
+		return PortRange{}, err
+	}
+	if !isRange {
+		return PortRange{Low: lo, High: lo}, nil
+	}
+	hi, err := parsePort(high)
+	if err != nil {
+		return PortRange{}, err
+	}
+	if hi < lo {
+		return PortRange{}, fmt.Errorf("invalid port range %q: high end below low end", s)
+	}
+	return PortRange{Low: lo, High: hi}, nil
+}
+
+func (p PortRange) String() string {
+	if p.Low == p.High {
+		return strconv.Itoa(p.Low)
+	}
+	return fmt.Sprintf("%d-%d", p.Low, p.High)
+}
+
+func (p PortRange) Contains(port int) bool {
+	return port >= p.Low && port <= p.High
+}
+
+func (p PortRange) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+func (p *PortRange) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParsePortRange(s)
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+type Decision string
+
+const (
+	Allow   Decision = "allow"
+	Block   Decision = "block"
+	NoMatch Decision = "no_match"
+)
+
+// Protocol is a transport protocol. It is always lowercase on the wire and
+// decoding rejects anything outside the known set.
+type Protocol string
+
+const (
+	ProtocolTCP  Protocol = "tcp"
+	ProtocolUDP  Protocol = "udp"
+	ProtocolICMP Protocol = "icmp"
+)
+
+var knownProtocols = map[Protocol]bool{
+	ProtocolTCP:  true,
+	ProtocolUDP:  true,
+	ProtocolICMP: true,
+}
+
+func ParseProtocol(s string) (Protocol, error) {
+	p := Protocol(strings.ToLower(strings.TrimSpace(s)))
+	if !knownProtocols[p] {
+		return "", fmt.Errorf("unknown protocol %q", s)
+	}
+	return p, nil
+}
+
+func (p *Protocol) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseProtocol(s)
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// stripZone drops surrounding brackets and an IPv6 zone ("%eth0") from an
+// address or CIDR, keeping any "/prefix" suffix.
+func stripZone(s string) string {
+	s = strings.TrimSpace(s)
+	addr, prefix, hasPrefix := strings.Cut(s, "/")
+	addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	addr, _, _ = strings.Cut(addr, "%")
+	if hasPrefix {
+		return addr + "/" + prefix
+	}
+	return addr
+}
+
+// parseIP parses an IPv4 or IPv6 address, ignoring brackets and any zone.
+func parseIP(s string) net.IP {
+	return net.ParseIP(stripZone(s))
+}
+
+func parseCIDR(s string) (*net.IPNet, error) {
+	_, network, err := net.ParseCIDR(stripZone(s))
+	return network, err
+}
+
+func validIPEntry(entry string) bool {
+	if _, err := parseCIDR(entry); err == nil {
+		return true
+	}
+	return parseIP(entry) != nil
+}
+
+// normalizeIPEntry rewrites an address or CIDR to its canonical form, so
+// "0:0:0:0:0:0:0:1" is stored as "::1" and "10.1.2.3/8" as "10.0.0.0/8".
+// Malformed entries are returned unchanged.
+func normalizeIPEntry(entry string) string {
+	if network, err := parseCIDR(entry); err == nil {
+		return network.String()
+	}
+	if ip := parseIP(entry); ip != nil {
+		return ip.String()
+	}
+	return entry
+}
+
+func normalizeIPList(entries []string) []string {
+	if entries == nil {
+		return nil
+	}
+	out := make([]string, len(entries))
+	for i, entry := range entries {
+		out[i] = normalizeIPEntry(entry)
+	}
+	return out
+}
+
+// normalizeIPs canonicalises the rule's IP lists.
+func (r *FirewallRule) normalizeIPs() {
+	r.AllowedIPs = normalizeIPList(r.AllowedIPs)
+	r.BlockedIPs = normalizeIPList(r.BlockedIPs)
+}
+
+// Validate reports every malformed field in the rule in a single error.
+func (r FirewallRule) Validate() error {
+	var problems []string
+	if strings.TrimSpace(r.AppName) == "" {
+		problems = append(problems, "app_name: must not be empty")
+	}
+	for i, entry := range r.AllowedIPs {
+		if !validIPEntry(entry) {
+			problems = append(problems, fmt.Sprintf("allowed_ips[%d]: %q is not an IP or CIDR", i, entry))
+		}
+	}
+	for i, entry := range r.BlockedIPs {
+		if !validIPEntry(entry) {
+			problems = append(problems, fmt.Sprintf("blocked_ips[%d]: %q is not an IP or CIDR", i, entry))
+		}
+	}
+	for i, port := range r.BlockedPorts {
+		if port < 1 || port > 65535 {
+			problems = append(problems, fmt.Sprintf("blocked_ports[%d]: %d is out of range", i, port))
+		}
+	}
+	if r.Schedule != nil {
+		if err := r.Schedule.Validate(); err != nil {
+			problems = append(problems, "schedule: "+strings.ReplaceAll(err.Error(), "\n", ", "))
+		}
+	}
+	if r.DefaultAction != "" && r.DefaultAction != Allow && r.DefaultAction != Block {
+		problems = append(problems, fmt.Sprintf("default_action: must be %q or %q", Allow, Block))
+	}
+	for i, proto := range r.AllowedProtocols {
+		if !knownProtocols[proto] {
+			problems = append(problems, fmt.Sprintf("allowed_protocols[%d]: unknown protocol %q", i, proto))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// matchIP reports whether ip equals a bare address or falls inside a CIDR entry.
+// Entries that parse as neither are ignored.
+func matchIP(entry string, ip net.IP) bool {
+	if network, err := parseCIDR(entry); err == nil {
+		return network.Contains(ip)
+	}
+	if parsed := parseIP(entry); parsed != nil {
+		return parsed.Equal(ip)
+	}
+	return false
+}
+
+// firstIPMatch returns the first entry that matches ip.
+func firstIPMatch(entries []string, ip net.IP) (string, bool) {
+	if ip == nil {
+		return "", false
+	}
+	for _, entry := range entries {
+		if matchIP(entry, ip) {
+			return entry, true
+		}
+	}
+	return "", false
+}
+
+func (r FirewallRule) AllowsIP(ip net.IP) bool {
+	_, ok := firstIPMatch(r.AllowedIPs, ip)
+	return ok
+}
+
+func (r FirewallRule) BlocksIP(ip net.IP) bool {
+	_, ok := firstIPMatch(r.BlockedIPs, ip)
+	return ok
+}
+
+// normalizeDomain puts a host, or a domain entry with an optional leading
+// "*." wildcard, in the form matching compares: trimmed, lowercase, without a
+// trailing dot and with internationalized labels in punycode, so
+// "CAFÉ.example." and "xn--caf-dma.example" are the same name. Names IDNA
+// rejects are only lowercased.
+func normalizeDomain(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	if isASCII(host) {
+		return host
+	}
+	wildcard, name := "", host
+	if rest, ok := strings.CutPrefix(host, "*."); ok {
+		wildcard, name = "*.", rest
+	}
+	if ascii, err := idna.Lookup.ToASCII(name); err == nil {
+		return wildcard + ascii
+	}
+	return host
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeDomainList applies normalizeDomain to each entry, except those
+// still holding ${NAME} variables, which are normalised when matched after
+// substitution.
+func normalizeDomainList(entries []string) []string {
+	if entries == nil {
+		return nil
+	}
+	out := make([]string, len(entries))
+	for i, entry := range entries {
+		if placeholderRE.MatchString(entry) {
+			out[i] = entry
+		} else {
+			out[i] = normalizeDomain(entry)
+		}
+	}
+	return out
+}
+
+// normalizeDomains canonicalises the rule's domain lists.
+func (r *FirewallRule) normalizeDomains() {
+	r.AllowedDomains = normalizeDomainList(r.AllowedDomains)
+	r.BlockedDomains = normalizeDomainList(r.BlockedDomains)
+}
+
+// matchDomain compares host against an exact entry or a leading "*." wildcard.
+// A wildcard matches any depth of subdomain but not the bare parent domain.
+func matchDomain(entry, host string) bool {
+	entry = normalizeDomain(entry)
+	if suffix, ok := strings.CutPrefix(entry, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return entry == host
+}
+
+// firstDomainMatch returns the first entry that matches host.
+func firstDomainMatch(entries []string, host string) (string, bool) {
+	host = normalizeDomain(host)
+	if host == "" {
+		return "", false
+	}
+	for _, entry := range entries {
+		if matchDomain(entry, host) {
+			return entry, true
+		}
+	}
+	return "", false
+}
+
+func (r FirewallRule) AllowsDomain(host string) bool {
+	_, ok := firstDomainMatch(r.AllowedDomains, host)
+	return ok
+}
+
+func (r FirewallRule) BlocksDomain(host string) bool {
+	_, ok := firstDomainMatch(r.BlockedDomains, host)
+	return ok
+}
+
+// AllowsPort treats an empty AllowedPorts list as allowing every port.
+func (r FirewallRule) AllowsPort(port int) bool {
+	if len(r.AllowedPorts) == 0 {
+		return true
+	}
+	for _, pr := range r.AllowedPorts {
+		if pr.Contains(port) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r FirewallRule) allowsProtocol(proto string) bool {
+	if len(r.AllowedProtocols) == 0 {
+		return true
+	}
+	want := Protocol(strings.ToLower(proto))
+	for _, p := range r.AllowedProtocols {
+		if p == want {
+			return true
+		}
+	}
+	return false
+}
+
+// EvalEnv is the context a rule is evaluated in.
+type EvalEnv struct {
+	Now time.Time
+	// Addrs, when set, resolves an allowed domain so a connection known only
+	// by IP can match it.
+	Addrs func(host string) []net.IP
+}
+
+// Evaluate decides a connection against the rule. Any block-list hit wins over
+// the allow-lists; otherwise the destination must match an allowed domain or IP
+// and use an allowed port and protocol (empty port or protocol lists allow any).
+// Outside the rule's Schedule only the block-lists apply. Anything left over
+// gets the rule's DefaultAction.
+func (r FirewallRule) Evaluate(domain string, ip net.IP, port int, proto string) Decision {
+	decision, _ := r.Explain(EvalEnv{Now: time.Now()}, domain, ip, port, proto)
+	return decision
+}
+
+// Explain is Evaluate in env that also returns the list entry responsible for
+// the decision, or "" when the default action applied.
+func (r FirewallRule) Explain(env EvalEnv, domain string, ip net.IP, port int, proto string) (Decision, string) {
+	if decision, entry := r.explainLists(env, domain, ip, port, proto); decision != NoMatch {
+		return decision, entry
+	}
+	if r.DefaultAction != "" {
+		return r.DefaultAction, ""
+	}
+	return NoMatch, ""
+}
+
+func (r FirewallRule) explainLists(env EvalEnv, domain string, ip net.IP, port int, proto string) (Decision, string) {
+	if entry, ok := firstDomainMatch(r.BlockedDomains, domain); ok {
+		return Block, entry
+	}
+	if entry, ok := firstIPMatch(r.BlockedIPs, ip); ok {
+		return Block, entry
+	}
+	for _, p := range r.BlockedPorts {
+		if p == port {
+			return Block, strconv.Itoa(p)
+		}
+	}
+	if r.Schedule != nil && !r.Schedule.Active(env.Now) {
+		return NoMatch, ""
+	}
+	if !r.AllowsPort(port) || !r.allowsProtocol(proto) {
+		return NoMatch, ""
+	}
+	if entry, ok := firstDomainMatch(r.AllowedDomains, domain); ok {
+		return Allow, entry
+	}
+	if entry, ok := firstIPMatch(r.AllowedIPs, ip); ok {
+		return Allow, entry
+	}
+	if entry, ok := r.resolvedDomainMatch(env, ip); ok {
+		return Allow, entry
+	}
+	return NoMatch, ""
+}
+
+// resolvedDomainMatch returns the first allowed domain that env resolves to ip.
+// Wildcard entries name no single host and are skipped. env.Addrs must not
+// block: the server's reads the DNS cache only.
+func (r FirewallRule) resolvedDomainMatch(env EvalEnv, ip net.IP) (string, bool) {
+	if ip == nil || env.Addrs == nil {
+		return "", false
+	}
+	for _, entry := range r.AllowedDomains {
+		if strings.HasPrefix(entry, "*.") {
+			continue
+		}
+		for _, addr := range env.Addrs(entry) {
+			if addr.Equal(ip) {
+				return entry, true
+			}
+		}
+	}
+	return "", false
+}
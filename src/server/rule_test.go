@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAllowsIP(t *testing.T) {
+	rule := FirewallRule{AllowedIPs: []string{"10.0.0.0/8", "1.2.3.4", "not-an-ip", "300.0.0.0/8"}}
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"10.255.255.255", true},
+		{"11.0.0.1", false},
+		{"1.2.3.4", true},
+		{"1.2.3.5", false},
+		{"192.168.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := rule.AllowsIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("AllowsIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+	if rule.AllowsIP(nil) {
+		t.Error("AllowsIP(nil) = true")
+	}
+}
+
+func TestAllowsDomain(t *testing.T) {
+	rule := FirewallRule{AllowedDomains: []string{"*.example.com", "Exact.ORG."}}
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"a.example.com", true},
+		{"a.b.example.com", true},
+		{"A.Example.COM.", true},
+		{"example.com", false},
+		{"badexample.com", false},
+		{"exact.org", true},
+		{"exact.org.", true},
+		{"sub.exact.org", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := rule.AllowsDomain(tt.host); got != tt.want {
+			t.Errorf("AllowsDomain(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+
+	rule.AllowedDomains = append(rule.AllowedDomains, "example.com")
+	if !rule.AllowsDomain("example.com") {
+		t.Error("bare parent not matched once listed")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     FirewallRule
+		problems []string
+	}{
+		{"valid", FirewallRule{AppName: "curl", AllowedIPs: []string{"10.0.0.0/8", "::1"}, AllowedProtocols: []Protocol{ProtocolTCP}}, nil},
+		{"empty app", FirewallRule{AppName: " "}, []string{"app_name"}},
+		{"bad ip", FirewallRule{AppName: "curl", AllowedIPs: []string{"10.0.0.1", "10.0.0.300"}}, []string{"allowed_ips[1]"}},
+		{"bad cidr", FirewallRule{AppName: "curl", AllowedIPs: []string{"10.0.0.0/33"}}, []string{"allowed_ips[0]"}},
+		{"bad protocol", FirewallRule{AppName: "curl", AllowedProtocols: []Protocol{"sctp"}}, []string{"allowed_protocols[0]"}},
+		{"bad blocked port", FirewallRule{AppName: "curl", BlockedPorts: []int{0}}, []string{"blocked_ports[0]"}},
+		{"every problem", FirewallRule{AllowedIPs: []string{"x"}, BlockedIPs: []string{"y"}, DefaultAction: "maybe"},
+			[]string{"app_name", "allowed_ips[0]", "blocked_ips[0]", "default_action"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if len(tt.problems) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Validate() = nil, want an error")
+			}
+			for _, field := range tt.problems {
+				if !strings.Contains(err.Error(), field) {
+					t.Errorf("error %q does not name %s", err, field)
+				}
+			}
+		})
+	}
+}
+
+func TestSetRuleRejectsInvalidRule(t *testing.T) {
+	h := newTestServer(t).Handler()
+	rec := call(t, h, "POST", "/v1/rule", `{"app_name": "curl", "allowed_ips": ["10.0.0.0/8", "nope"]}`)
+	expect(t, rec, http.StatusBadRequest)
+	var apiErr APIError
+	decode(t, rec, &apiErr)
+	if apiErr.Code != CodeValidationFailed || !strings.Contains(apiErr.Message, "allowed_ips[1]") {
+		t.Errorf("error = %+v, want VALIDATION_FAILED naming allowed_ips[1]", apiErr)
+	}
+	expect(t, call(t, h, "GET", "/v1/rule/curl", nil), http.StatusNotFound)
+}
+
+func TestEvaluateBlocksOverrideAllows(t *testing.T) {
+	rule := FirewallRule{
+		AllowedDomains: []string{"*.example.com"},
+		AllowedIPs:     []string{"10.0.0.0/8"},
+		BlockedDomains: []string{"ads.example.com"},
+		BlockedIPs:     []string{"10.0.0.5"},
+		BlockedPorts:   []int{25},
+	}
+	tests := []struct {
+		name   string
+		domain string
+		ip     string
+		port   int
+		want   Decision
+	}{
+		{"allowed domain", "cdn.example.com", "", 443, Allow},
+		{"allowed ip", "", "10.1.1.1", 443, Allow},
+		{"ip in both lists", "", "10.0.0.5", 443, Block},
+		{"domain in both lists", "ads.example.com", "", 443, Block},
+		{"blocked port on allowed ip", "", "10.1.1.1", 25, Block},
+		{"unlisted", "other.org", "192.0.2.1", 443, NoMatch},
+	}
+	for _, tt := range tests {
+		if got := rule.Evaluate(tt.domain, net.ParseIP(tt.ip), tt.port, "tcp"); got != tt.want {
+			t.Errorf("%s: Evaluate = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	allowOnly := FirewallRule{AllowedIPs: []string{"10.0.0.0/8"}}
+	if got := allowOnly.Evaluate("", net.ParseIP("10.0.0.5"), 443, "tcp"); got != Allow {
+		t.Errorf("allow-only rule: Evaluate = %s, want allow", got)
+	}
+}
+
+func TestParsePortRange(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    PortRange
+		wantErr bool
+	}{
+		{"443", PortRange{443, 443}, false},
+		{"8000-8100", PortRange{8000, 8100}, false},
+		{"1-65535", PortRange{1, 65535}, false},
+		{"0", PortRange{}, true},
+		{"65536", PortRange{}, true},
+		{"8100-8000", PortRange{}, true},
+		{"80-", PortRange{}, true},
+		{"http", PortRange{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePortRange(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParsePortRange(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestAllowsPort(t *testing.T) {
+	var rule FirewallRule
+	if err := json.Unmarshal([]byte(`{"allowed_ports": ["443", "8000-8100"]}`), &rule); err != nil {
+		t.Fatal(err)
+	}
+	for port, want := range map[int]bool{443: true, 442: false, 444: false, 7999: false, 8000: true, 8050: true, 8100: true, 8101: false} {
+		if got := rule.AllowsPort(port); got != want {
+			t.Errorf("AllowsPort(%d) = %v, want %v", port, got, want)
+		}
+	}
+	if !(FirewallRule{}).AllowsPort(22) {
+		t.Error("empty AllowedPorts does not allow every port")
+	}
+}
+
+func TestIPv6Forms(t *testing.T) {
+	rule := FirewallRule{AllowedIPs: []string{"2001:DB8::1", "fe80::1%eth0", "[2001:db8:0:1::]/64", "::ffff:10.0.0.0/104"}}
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"2001:db8::1", true},
+		{"2001:0db8:0000:0000:0000:0000:0000:0001", true},
+		{"[2001:db8::1]", true},
+		{"2001:db8::2", false},
+		{"fe80::1", true},
+		{"fe80::1%wlan0", true},
+		{"fe80::2%eth0", false},
+		{"2001:db8:0:1:ffff::1", true},
+		{"2001:db8:0:2::1", false},
+		{"10.1.2.3", true},
+		{"::ffff:10.1.2.3", true},
+	}
+	for _, tt := range tests {
+		if got := rule.AllowsIP(parseIP(tt.ip)); got != tt.want {
+			t.Errorf("AllowsIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+
+	for entry, want := range map[string]string{
+		"0:0:0:0:0:0:0:1":        "::1",
+		"2001:DB8:0:0:0:0:0:1":   "2001:db8::1",
+		"fe80::1%eth0":           "fe80::1",
+		"[2001:db8::1]":          "2001:db8::1",
+		"2001:db8:0:1:abcd::/64": "2001:db8:0:1::/64",
+	} {
+		if got := normalizeIPEntry(entry); got != want {
+			t.Errorf("normalizeIPEntry(%q) = %q, want %q", entry, got, want)
+		}
+	}
+}
+
+func TestStoredIPv6IsCanonical(t *testing.T) {
+	h := newTestServer(t).Handler()
+	rule := putRule(t, h, FirewallRule{AppName: "curl", AllowedIPs: []string{"0:0:0:0:0:0:0:1", "FE80::1%eth0"}, Enabled: true})
+	if strings.Join(rule.AllowedIPs, ",") != "::1,fe80::1" {
+		t.Errorf("stored allowed_ips = %v, want [::1 fe80::1]", rule.AllowedIPs)
+	}
+
+	rec := call(t, h, "POST", "/v1/rule/curl/evaluate", evaluateRequest{IP: "fe80::1%en0", Port: 443, Protocol: "tcp"})
+	expect(t, rec, http.StatusOK)
+	var got evaluateResponse
+	decode(t, rec, &got)
+	if got.Decision != Allow {
+		t.Errorf("zoned address: decision %s, want allow", got.Decision)
+	}
+}
+
+func TestDefaultAction(t *testing.T) {
+	tests := []struct {
+		name   string
+		action Decision
+		domain string
+		want   Decision
+	}{
+		{"block by default", Block, "other.org", Block},
+		{"allow by default", Allow, "other.org", Allow},
+		{"legacy rule without one", "", "other.org", NoMatch},
+		{"allow-list still applies", Block, "example.com", Allow},
+		{"block-list beats allow default", Allow, "ads.example.com", Block},
+	}
+	for _, tt := range tests {
+		rule := FirewallRule{AllowedDomains: []string{"example.com"}, BlockedDomains: []string{"ads.example.com"}, DefaultAction: tt.action}
+		if got := rule.Evaluate(tt.domain, nil, 443, "tcp"); got != tt.want {
+			t.Errorf("%s: Evaluate(%s) = %s, want %s", tt.name, tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestStoredDefaultAction(t *testing.T) {
+	h := newTestServer(t).Handler()
+	if rule := putRule(t, h, FirewallRule{AppName: "curl", Enabled: true}); rule.DefaultAction != Block {
+		t.Errorf("new rule default_action = %q, want block", rule.DefaultAction)
+	}
+	putRule(t, h, FirewallRule{AppName: "curl", DefaultAction: Allow, Enabled: true})
+	if rule := putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"example.com"}, Enabled: true}); rule.DefaultAction != Allow {
+		t.Errorf("update without default_action = %q, want the stored allow kept", rule.DefaultAction)
+	}
+
+	rec := call(t, h, "POST", "/v1/rule", `{"app_name": "wget", "default_action": "maybe"}`)
+	expect(t, rec, http.StatusBadRequest)
+}
+
+func TestInternationalDomains(t *testing.T) {
+	tests := []struct {
+		entry, host string
+		want        bool
+	}{
+		{"bücher.example", "xn--bcher-kva.example", true},
+		{"xn--bcher-kva.example", "bücher.example", true},
+		{"Bücher.Example.", "BÜCHER.example", true},
+		{"*.münchen.example", "shop.xn--mnchen-3ya.example", true},
+		{"*.xn--mnchen-3ya.example", "shop.münchen.example", true},
+		{"*.münchen.example", "münchen.example", false},
+		{"bücher.example", "bucher.example", false},
+	}
+	for _, tt := range tests {
+		rule := FirewallRule{AllowedDomains: []string{tt.entry}}
+		if got := rule.AllowsDomain(tt.host); got != tt.want {
+			t.Errorf("%q allows %q = %v, want %v", tt.entry, tt.host, got, tt.want)
+		}
+	}
+
+	h := newTestServer(t).Handler()
+	rule := putRule(t, h, FirewallRule{AppName: "curl", BlockedDomains: []string{"bücher.example"}, Enabled: true})
+	if strings.Join(rule.BlockedDomains, ",") != "xn--bcher-kva.example" {
+		t.Errorf("stored blocked_domains = %v, want the punycode form", rule.BlockedDomains)
+	}
+	for _, domain := range []string{"bücher.example", "xn--bcher-kva.example"} {
+		rec := call(t, h, "POST", "/v1/rule/curl/evaluate", evaluateRequest{Domain: domain, Port: 443, Protocol: "tcp"})
+		expect(t, rec, http.StatusOK)
+		var got evaluateResponse
+		if decode(t, rec, &got); got.Decision != Block {
+			t.Errorf("%s: decision %s, want block", domain, got.Decision)
+		}
+	}
+}
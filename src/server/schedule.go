@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Clock supplies the current time so schedule checks can be tested.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// Schedule limits when a rule's allow-lists apply. Days holds weekday names
+// ("mon" or "monday"); empty means every day. StartTime and EndTime are
+// "HH:MM" in Timezone (UTC when empty). A window whose end is before its start
+// runs overnight into the following day.
+type Schedule struct {
+	Days      []string `json:"days,omitempty"`
+	StartTime string   `json:"start_time"`
+	EndTime   string   `json:"end_time"`
+	Timezone  string   `json:"timezone,omitempty"`
+}
+
+var weekdayNames = map[string]time.Weekday{}
+
+func init() {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		weekdayNames[name] = d
+		weekdayNames[name[:3]] = d
+	}
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (sc Schedule) Validate() error {
+	var errs []error
+	for _, day := range sc.Days {
+		if _, ok := weekdayNames[strings.ToLower(day)]; !ok {
+			errs = append(errs, fmt.Errorf("unknown day %q", day))
+		}
+	}
+	start, err := parseClock(sc.StartTime)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	end, err := parseClock(sc.EndTime)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if err == nil && start == end {
+		errs = append(errs, errors.New("start_time and end_time must differ"))
+	}
+	if _, err := time.LoadLocation(sc.Timezone); err != nil {
+		errs = append(errs, fmt.Errorf("unknown timezone %q", sc.Timezone))
+	}
+	return errors.Join(errs...)
+}
+
+func (sc Schedule) onDay(d time.Weekday) bool {
+	if len(sc.Days) == 0 {
+		return true
+	}
+	for _, day := range sc.Days {
+		if weekdayNames[strings.ToLower(day)] == d {
+			return true
+		}
+	}
+	return false
+}
+
+// Active reports whether t falls inside the schedule's window. A schedule
+// that fails Validate is never active.
+func (sc Schedule) Active(t time.Time) bool {
+	loc, err := time.LoadLocation(sc.Timezone)
+	if err != nil {
+		return false
+	}
+	start, err := parseClock(sc.StartTime)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(sc.EndTime)
+	if err != nil {
+		return false
+	}
+
+	t = t.In(loc)
+	minute := t.Hour()*60 + t.Minute()
+	if start < end {
+		return sc.onDay(t.Weekday()) && minute >= start && minute < end
+	}
+	// Overnight: the early-morning part belongs to the previous day's window.
+	yesterday := (t.Weekday() + 6) % 7
+	return (sc.onDay(t.Weekday()) && minute >= start) || (sc.onDay(yesterday) && minute < end)
+}
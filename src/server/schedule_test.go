@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fixedClock is a Clock stopped at one instant.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+// at returns the UTC time on the given day of the week starting Monday
+// 2024-01-01.
+func at(day time.Weekday, hhmm string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", "2024-01-01 "+hhmm)
+	if err != nil {
+		panic(err)
+	}
+	return t.AddDate(0, 0, (int(day)+6)%7)
+}
+
+func TestScheduleActive(t *testing.T) {
+	office := Schedule{Days: []string{"mon", "Tuesday"}, StartTime: "09:00", EndTime: "17:00"}
+	overnight := Schedule{Days: []string{"fri"}, StartTime: "22:00", EndTime: "06:00"}
+	tokyo := Schedule{StartTime: "09:00", EndTime: "17:00", Timezone: "Asia/Tokyo"}
+
+	tests := []struct {
+		name string
+		sc   Schedule
+		t    time.Time
+		want bool
+	}{
+		{"inside window", office, at(time.Monday, "09:00"), true},
+		{"end is exclusive", office, at(time.Monday, "17:00"), false},
+		{"before start", office, at(time.Tuesday, "08:59"), false},
+		{"other day", office, at(time.Wednesday, "12:00"), false},
+		{"overnight evening", overnight, at(time.Friday, "23:30"), true},
+		{"overnight next morning", overnight, at(time.Saturday, "05:59"), true},
+		{"overnight ended", overnight, at(time.Saturday, "06:00"), false},
+		{"overnight morning of listed day", overnight, at(time.Friday, "05:00"), false},
+		{"timezone inside", tokyo, at(time.Monday, "01:00"), true},
+		{"timezone outside", tokyo, at(time.Monday, "09:00"), false},
+		{"invalid schedule", Schedule{StartTime: "9am", EndTime: "17:00"}, at(time.Monday, "12:00"), false},
+	}
+	for _, tt := range tests {
+		if got := tt.sc.Active(tt.t); got != tt.want {
+			t.Errorf("%s: Active(%s) = %v, want %v", tt.name, tt.t.Format("Mon 15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestScheduledRuleUsesClock(t *testing.T) {
+	s := newTestServer(t)
+	h := s.Handler()
+	putRule(t, h, FirewallRule{
+		AppName:        "backup",
+		AllowedDomains: []string{"backup.example"},
+		BlockedDomains: []string{"ads.example"},
+		Schedule:       &Schedule{StartTime: "22:00", EndTime: "06:00"},
+		Enabled:        true,
+	})
+
+	tests := []struct {
+		now    time.Time
+		domain string
+		want   Decision
+	}{
+		{at(time.Sunday, "23:00"), "backup.example", Allow},
+		{at(time.Monday, "03:00"), "backup.example", Allow},
+		{at(time.Monday, "12:00"), "backup.example", Block},
+		{at(time.Monday, "03:00"), "ads.example", Block},
+	}
+	for _, tt := range tests {
+		s.Clock = fixedClock(tt.now)
+		rec := call(t, h, "POST", "/v1/rule/backup/evaluate", evaluateRequest{Domain: tt.domain, Port: 443, Protocol: "tcp"})
+		expect(t, rec, http.StatusOK)
+		var got evaluateResponse
+		decode(t, rec, &got)
+		if got.Decision != tt.want {
+			t.Errorf("%s at %s: decision %s, want %s", tt.domain, tt.now.Format("Mon 15:04"), got.Decision, tt.want)
+		}
+	}
+}
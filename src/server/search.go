@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+)
+
+// ruleSearch selects rules whose allow or block lists match every set field.
+type ruleSearch struct {
+	Domain string
+	IP     net.IP
+}
+
+func (q ruleSearch) matches(rule FirewallRule) bool {
+	if q.Domain != "" && !rule.AllowsDomain(q.Domain) && !rule.BlocksDomain(q.Domain) {
+		return false
+	}
+	if q.IP != nil && !rule.AllowsIP(q.IP) && !rule.BlocksIP(q.IP) {
+		return false
+	}
+	return true
+}
+
+func (s *CentralServer) HandleSearchRules(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	q := ruleSearch{Domain: params.Get("domain")}
+	if v := params.Get("ip"); v != "" {
+		if q.IP = parseIP(v); q.IP == nil {
+			writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Invalid ip")
+			return
+		}
+	}
+	if q.Domain == "" && q.IP == nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "domain or ip is required")
+		return
+	}
+
+	s.mu.RLock()
+	rules, err := s.store.List(r.Context())
+	s.mu.RUnlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+
+	// Rules are matched as evaluation sees them, templates merged in and
+	// variables substituted, but returned as stored.
+	enabled := make(map[string]FirewallRule, len(rules))
+	for _, rule := range rules {
+		if rule.Enabled {
+			enabled[rule.AppName] = rule
+		}
+	}
+	get := func(appName string) (FirewallRule, error) {
+		if rule, ok := enabled[appName]; ok {
+			return rule, nil
+		}
+		return FirewallRule{}, ErrRuleNotFound
+	}
+	matched := []FirewallRule{}
+	for _, rule := range rules {
+		effective, err := s.effectiveWith(rule, get)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, CodeInternal, err.Error())
+			return
+		}
+		if q.matches(effective) {
+			matched = append(matched, rule)
+		}
+	}
+	json.NewEncoder(w).Encode(matched)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func searchRules(t *testing.T, h http.Handler, query string) []string {
+	t.Helper()
+	rec := call(t, h, "GET", "/v1/rules/search?"+query, nil)
+	expect(t, rec, http.StatusOK)
+	var rules []FirewallRule
+	decode(t, rec, &rules)
+	names := []string{}
+	for _, rule := range rules {
+		names = append(names, rule.AppName)
+	}
+	return names
+}
+
+func TestSearchRules(t *testing.T) {
+	s := newTestServer(t)
+	s.Variables = map[string]string{"INTERNAL_CIDR": "172.16.0.0/12"}
+	h := s.Handler()
+	putRule(t, h, FirewallRule{AppName: "base", AllowedDomains: []string{"*.corp.example"}, Template: true, Enabled: true})
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedIPs: []string{"10.0.0.0/8"}, BlockedDomains: []string{"ads.example"}, Templates: []string{"base"}, Enabled: true})
+	putRule(t, h, FirewallRule{AppName: "wget", AllowedIPs: []string{"${INTERNAL_CIDR}"}, AllowedDomains: []string{"ads.example"}, Enabled: true})
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"ip=10.1.2.3", []string{"curl"}},
+		{"ip=172.20.0.1", []string{"wget"}},
+		{"domain=ads.example", []string{"curl", "wget"}},
+		{"domain=git.corp.example", []string{"base", "curl"}},
+		{"domain=git.corp.example&ip=10.1.2.3", []string{"curl"}},
+		{"domain=ads.example&ip=10.1.2.3", []string{"curl"}},
+		{"ip=192.0.2.1", []string{}},
+	}
+	for _, tt := range tests {
+		if got := searchRules(t, h, tt.query); !slices.Equal(got, tt.want) {
+			t.Errorf("search %s = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	expect(t, call(t, h, "GET", "/v1/rules/search", nil), http.StatusBadRequest)
+	expect(t, call(t, h, "GET", "/v1/rules/search?ip=nope", nil), http.StatusBadRequest)
+}
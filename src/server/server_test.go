@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testToken = "test-token"
+
+func TestMain(m *testing.M) {
+	// Every request is logged; keep test output to failures.
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+// newTestServer returns a server on a fresh SQLite store that accepts
+// testToken. DNS matching and log rate limiting are off, so tests neither
+// touch the network nor trip over each other's requests.
+func newTestServer(t testing.TB) *CentralServer {
+	t.Helper()
+	store, err := NewSQLiteRuleStore(filepath.Join(t.TempDir(), "firewall.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	s := NewCentralServer(store)
+	s.AuthToken = testToken
+	s.DNS = nil
+	s.logLimiter.SetLimit(0, 0)
+	return s
+}
+
+// newRequest builds a request carrying testToken. A string body is sent as
+// written, anything else but nil as JSON.
+func newRequest(t testing.TB, method, path string, body any) *http.Request {
+	t.Helper()
+	var r io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		r = strings.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, r)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	return req
+}
+
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// call sends an authenticated request through h.
+func call(t testing.TB, h http.Handler, method, path string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	return serve(h, newRequest(t, method, path, body))
+}
+
+// expect fails the test unless rec has the given status.
+func expect(t testing.TB, rec *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, status, rec.Body)
+	}
+}
+
+func decode(t testing.TB, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body, err)
+	}
+}
+
+// putRule stores rule through the API and returns it as stored.
+func putRule(t testing.TB, h http.Handler, rule FirewallRule) FirewallRule {
+	t.Helper()
+	rec := call(t, h, "POST", "/v1/rule", rule)
+	expect(t, rec, http.StatusCreated)
+	var stored FirewallRule
+	decode(t, rec, &stored)
+	return stored
+}
+
+func TestConcurrentRuleAndLogAccess(t *testing.T) {
+	s := newTestServer(t)
+	h := s.Handler()
+
+	const workers = 50
+	var wg sync.WaitGroup
+	errs := make(chan string, workers*4)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			app := fmt.Sprintf("app-%d", i%5)
+			check := func(what string, rec *httptest.ResponseRecorder, ok ...int) {
+				for _, status := range ok {
+					if rec.Code == status {
+						return
+					}
+				}
+				errs <- fmt.Sprintf("%s: status %d: %s", what, rec.Code, rec.Body)
+			}
+			rule := FirewallRule{AppName: app, AllowedDomains: []string{fmt.Sprintf("host%d.example.com", i)}, Enabled: true}
+			check("set rule", call(t, h, "POST", "/v1/rule", rule), http.StatusCreated)
+			check("get rule", call(t, h, "GET", "/v1/rule/"+app, nil), http.StatusOK, http.StatusNotFound)
+			entry := NetworkLog{AppName: app, RemoteIP: "10.0.0.1", Port: 443, Action: ActionAllowed}
+			check("receive log", call(t, h, "POST", "/v1/logs", entry), http.StatusCreated)
+			check("get logs", call(t, h, "GET", "/v1/logs?app_name="+app, nil), http.StatusOK)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	var page logPage
+	decode(t, call(t, h, "GET", "/v1/logs?limit=1000", nil), &page)
+	if page.Total != workers {
+		t.Errorf("stored %d logs, want %d", page.Total, workers)
+	}
+	for i := 0; i < 5; i++ {
+		var rule resolvedRule
+		decode(t, call(t, h, "GET", fmt.Sprintf("/v1/rule/app-%d", i), nil), &rule)
+		if rule.Version != workers/5 {
+			t.Errorf("app-%d is at version %d, want %d", i, rule.Version, workers/5)
+		}
+	}
+}
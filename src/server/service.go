@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidRule     = errors.New("invalid rule")
+	ErrVersionConflict = errors.New("version conflict")
+)
+
+// GetRule, SetRule and DeleteRule are the rule operations shared by the HTTP
+// and gRPC APIs. The actor for auditing is taken from ctx.
+
+func (s *CentralServer) GetRule(ctx context.Context, appName string) (FirewallRule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.enabledRuleLocked(ctx, appName)
+}
+
+// enabledRuleLocked is store.Get reporting disabled rules as not found.
+// Callers hold s.mu.
+func (s *CentralServer) enabledRuleLocked(ctx context.Context, appName string) (FirewallRule, error) {
+	rule, err := s.store.Get(ctx, appName)
+	if err == nil && !rule.Enabled {
+		return FirewallRule{}, ErrRuleNotFound
+	}
+	return rule, err
+}
+
+// DefaultRuleName is the reserved app name of the catch-all rule applied to
+// apps that have no rule of their own.
+const DefaultRuleName = "*"
+
+// ResolveRule returns appName's rule, falling back to the default rule when
+// the app has none or its rule is disabled. The bool reports whether the
+// fallback was used.
+func (s *CentralServer) ResolveRule(ctx context.Context, appName string) (FirewallRule, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rule, err := s.enabledRuleLocked(ctx, appName)
+	if !errors.Is(err, ErrRuleNotFound) || appName == DefaultRuleName {
+		return rule, false, err
+	}
+	rule, err = s.enabledRuleLocked(ctx, DefaultRuleName)
+	return rule, err == nil, err
+}
+
+// SetRule validates and stores rule, returning it with its new version. A
+// non-zero rule.Version must match the stored version.
+func (s *CentralServer) SetRule(ctx context.Context, rule FirewallRule) (FirewallRule, error) {
+	s.mu.Lock()
+	if err := s.checkRuleLocked(ctx, rule); err != nil {
+		s.mu.Unlock()
+		return rule, err
+	}
+	current, err := s.store.Get(ctx, rule.AppName)
+	if err != nil && !errors.Is(err, ErrRuleNotFound) {
+		s.mu.Unlock()
+		return rule, err
+	}
+	if rule.Version != 0 && rule.Version != current.Version {
+		s.mu.Unlock()
+		return rule, fmt.Errorf("%w: rule is at version %d", ErrVersionConflict, current.Version)
+	}
+	err = s.storeRuleLocked(ctx, &rule, current)
+	s.mu.Unlock()
+	return rule, err
+}
+
+// checkRuleLocked applies the checks a rule must pass before it is stored:
+// it is valid with variables substituted and its templates resolve. Callers
+// hold s.mu.
+func (s *CentralServer) checkRuleLocked(ctx context.Context, rule FirewallRule) error {
+	if err := s.validateRule(rule); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRule, err)
+	}
+	get := func(appName string) (FirewallRule, error) { return s.store.Get(ctx, appName) }
+	return checkTemplates(rule, get)
+}
+
+func (s *CentralServer) DeleteRule(ctx context.Context, appName string) error {
+	s.mu.Lock()
+	before, err := s.store.Get(ctx, appName)
+	if err == nil {
+		err = s.checkWritableLocked()
+	}
+	if err == nil && before.Template {
+		var users []string
+		if users, err = s.templateUsersLocked(ctx, appName); err == nil && len(users) > 0 {
+			err = fmt.Errorf("%w: used by %s", ErrTemplateInUse, strings.Join(users, ", "))
+		}
+	}
+	if err == nil {
+		err = s.store.Delete(ctx, appName)
+	}
+	if err == nil {
+		s.recordAuditLocked(ctx, AuditDelete, &before, nil)
+		s.ruleDeletedLocked(appName)
+	}
+	s.mu.Unlock()
+	return err
+}
+
+// DeleteRulesWithPrefix removes every rule whose app name starts with prefix
+// in one transaction, auditing each, and returns the names removed. It
+// refuses to remove a template still used by a rule that would survive.
+func (s *CentralServer) DeleteRulesWithPrefix(ctx context.Context, prefix string) ([]string, error) {
+	s.mu.Lock()
+	if err := s.checkWritableLocked(); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	rules, err := s.store.List(ctx)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	var doomed []FirewallRule
+	names := []string{}
+	deleting := map[string]bool{}
+	for _, rule := range rules {
+		if strings.HasPrefix(rule.AppName, prefix) {
+			doomed = append(doomed, rule)
+			names = append(names, rule.AppName)
+			deleting[rule.AppName] = true
+		}
+	}
+	for _, rule := range rules {
+		if deleting[rule.AppName] {
+			continue
+		}
+		for _, name := range rule.Templates {
+			if deleting[name] {
+				s.mu.Unlock()
+				return nil, fmt.Errorf("%w: %s is used by %s", ErrTemplateInUse, name, rule.AppName)
+			}
+		}
+	}
+	if len(names) > 0 {
+		err = s.store.DeleteAll(ctx, names)
+	}
+	if err == nil {
+		for i := range doomed {
+			s.recordAuditLocked(ctx, AuditDelete, &doomed[i], nil)
+			s.ruleDeletedLocked(doomed[i].AppName)
+		}
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// storeRuleLocked writes rule as the successor of current, which is the zero
+// rule when none existed, audits the change and notifies streams. Callers
+// hold s.mu.
+func (s *CentralServer) storeRuleLocked(ctx context.Context, rule *FirewallRule, current FirewallRule) error {
+	if err := s.checkWritableLocked(); err != nil {
+		return err
+	}
+	rule.prepareSuccessor(current)
+	rule.Version = current.Version + 1
+	rule.UpdatedAt = time.Now().UTC()
+	if err := s.store.Set(ctx, *rule); err != nil {
+		return err
+	}
+	s.recordHistoryLocked(ctx, *rule)
+
+	after := *rule
+	if current.AppName == "" {
+		s.recordAuditLocked(ctx, AuditCreate, nil, &after)
+	} else {
+		s.recordAuditLocked(ctx, AuditUpdate, &current, &after)
+	}
+	s.ruleStoredLocked(after)
+	return nil
+}
+
+// prepareSuccessor normalises rule before it replaces current (the zero rule
+// for a create): IPs and domains are canonicalised and a missing
+// DefaultAction is inherited, or Block for a new rule.
+func (r *FirewallRule) prepareSuccessor(current FirewallRule) {
+	r.normalizeIPs()
+	r.normalizeDomains()
+	if r.DefaultAction != "" {
+		return
+	}
+	if current.AppName == "" {
+		r.DefaultAction = Block
+	} else {
+		r.DefaultAction = current.DefaultAction
+	}
+}
+
+// ruleStoredLocked and ruleDeletedLocked record a committed change and
+// notify stream subscribers. Publishing before s.mu is released keeps events
+// in version order. Callers hold s.mu.
+func (s *CentralServer) ruleStoredLocked(rule FirewallRule) {
+	rulesSetTotal.Inc()
+	s.publishRule(RuleEvent{Type: RuleEventSet, AppName: rule.AppName, Rule: &rule})
+}
+
+func (s *CentralServer) ruleDeletedLocked(appName string) {
+	rulesDeletedTotal.Inc()
+	s.publishRule(RuleEvent{Type: RuleEventDelete, AppName: appName})
+}
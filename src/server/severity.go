@@ -0,0 +1,43 @@
+package main
+
+import "net"
+
+// Severity ranks how interesting a logged connection is.
+type Severity string
+
+const (
+	SeverityInfo   Severity = "info"
+	SeverityLow    Severity = "low"
+	SeverityMedium Severity = "medium"
+	SeverityHigh   Severity = "high"
+)
+
+// LogClassifier derives a log's Severity on ingestion.
+type LogClassifier func(NetworkLog) Severity
+
+// severityClassifier is the default LogClassifier. Allowed connections are
+// info. Blocked ones are high when the remote domain or IP is on the given
+// high-risk lists, which take the same entries as rule lists, medium when
+// the remote is an internal address, and low otherwise.
+func severityClassifier(highRiskDomains, highRiskIPs []string) LogClassifier {
+	return func(entry NetworkLog) Severity {
+		if entry.Action != ActionBlocked {
+			return SeverityInfo
+		}
+		ip := parseIP(entry.RemoteIP)
+		if _, ok := firstDomainMatch(highRiskDomains, entry.RemoteDomain); ok {
+			return SeverityHigh
+		}
+		if _, ok := firstIPMatch(highRiskIPs, ip); ok {
+			return SeverityHigh
+		}
+		if isInternalIP(ip) {
+			return SeverityMedium
+		}
+		return SeverityLow
+	}
+}
+
+func isInternalIP(ip net.IP) bool {
+	return ip != nil && (ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast())
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSeverityClassifier(t *testing.T) {
+	classify := severityClassifier([]string{"*.evil.example"}, []string{"198.51.100.0/24"})
+	tests := []struct {
+		name  string
+		entry NetworkLog
+		want  Severity
+	}{
+		{"allowed high-risk domain", NetworkLog{RemoteDomain: "c2.evil.example", Action: ActionAllowed}, SeverityInfo},
+		{"blocked high-risk domain", NetworkLog{RemoteDomain: "c2.evil.example", RemoteIP: "10.0.0.1", Action: ActionBlocked}, SeverityHigh},
+		{"blocked high-risk ip", NetworkLog{RemoteIP: "198.51.100.7", Action: ActionBlocked}, SeverityHigh},
+		{"blocked internal ip", NetworkLog{RemoteIP: "192.168.1.10", Action: ActionBlocked}, SeverityMedium},
+		{"blocked public ip", NetworkLog{RemoteIP: "203.0.113.1", RemoteDomain: "example.com", Action: ActionBlocked}, SeverityLow},
+	}
+	for _, tt := range tests {
+		if got := classify(tt.entry); got != tt.want {
+			t.Errorf("%s: severity %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStoredLogsAreClassified(t *testing.T) {
+	s := newTestServer(t)
+	s.Classify = severityClassifier([]string{"*.evil.example"}, nil)
+	h := s.Handler()
+	for _, entry := range []NetworkLog{
+		{AppName: "curl", RemoteDomain: "c2.evil.example", RemoteIP: "203.0.113.1", Port: 443, Action: ActionBlocked},
+		{AppName: "curl", RemoteDomain: "example.com", RemoteIP: "203.0.113.2", Port: 443, Action: ActionBlocked},
+		// A client can't pick its own severity.
+		{AppName: "curl", RemoteDomain: "example.com", RemoteIP: "203.0.113.2", Port: 443, Action: ActionAllowed, Severity: SeverityHigh},
+	} {
+		expect(t, call(t, h, "POST", "/v1/logs", entry), http.StatusCreated)
+	}
+
+	var page logPage
+	decode(t, call(t, h, "GET", "/v1/logs?severity=high", nil), &page)
+	if len(page.Items) != 1 || page.Items[0].RemoteDomain != "c2.evil.example" {
+		t.Errorf("high severity logs = %+v, want the blocked one to c2.evil.example", page.Items)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// simulateRequest is a complete candidate rule set, in the export format or
+// as a bare array, and the window of stored logs to replay through it. Zero
+// bounds leave that side open.
+type simulateRequest struct {
+	Rules json.RawMessage `json:"rules"`
+	Since time.Time       `json:"since"`
+	Until time.Time       `json:"until"`
+}
+
+// simulateCounts splits the replayed connections the candidate rules would
+// treat differently. NoMatch counts those no candidate decides, which are
+// left to the agent as they would be live, whatever was logged for them.
+type simulateCounts struct {
+	NewlyBlocked int `json:"newly_blocked"`
+	NewlyAllowed int `json:"newly_allowed"`
+	NoMatch      int `json:"no_match"`
+}
+
+// simulateResult counts the replayed connections whose outcome the
+// candidate rules would flip or leave undecided, overall and for each app
+// with any.
+type simulateResult struct {
+	Evaluated int `json:"evaluated"`
+	simulateCounts
+	ByApp map[string]simulateCounts `json:"by_app"`
+}
+
+// candidateRules validates a rule set that is to replace the live one as a
+// whole, so templates resolve within the set. Problems are returned by index.
+func (s *CentralServer) candidateRules(rules []FirewallRule) (map[string]FirewallRule, []importError) {
+	byName := make(map[string]FirewallRule, len(rules))
+	problems := []importError{}
+	for i, rule := range rules {
+		if err := s.validateRule(rule); err != nil {
+			problems = append(problems, importError{Index: i, Error: err.Error()})
+			continue
+		}
+		if _, dup := byName[rule.AppName]; dup {
+			problems = append(problems, importError{Index: i, Error: fmt.Sprintf("duplicate app_name %q", rule.AppName)})
+			continue
+		}
+		rule.normalizeIPs()
+		rule.normalizeDomains()
+		if rule.DefaultAction == "" {
+			rule.DefaultAction = Block
+		}
+		byName[rule.AppName] = rule
+	}
+	get := func(appName string) (FirewallRule, error) {
+		if rule, ok := byName[appName]; ok {
+			return rule, nil
+		}
+		return FirewallRule{}, ErrRuleNotFound
+	}
+	for i, rule := range rules {
+		if err := checkTemplates(rule, get); err != nil {
+			problems = append(problems, importError{Index: i, Error: err.Error()})
+		}
+	}
+	return byName, problems
+}
+
+// simulate replays the stored logs timestamped within [since, until]
+// through rules and compares each outcome with the action recorded for it.
+// Schedules are evaluated at each log's own time and allowed domains match
+// IPs through the DNS cache as a live decision would, so a domain not yet
+// cached matches nothing. Nothing is stored.
+func (s *CentralServer) simulate(rules map[string]FirewallRule, since, until time.Time) (simulateResult, error) {
+	enabled := func(appName string) (FirewallRule, error) {
+		if rule, ok := rules[appName]; ok && rule.Enabled {
+			return rule, nil
+		}
+		return FirewallRule{}, ErrRuleNotFound
+	}
+	// Resolved like ResolveRule and EffectiveRule, against the candidates.
+	resolved := map[string]*FirewallRule{}
+	resolve := func(appName string) (*FirewallRule, error) {
+		if rule, ok := resolved[appName]; ok {
+			return rule, nil
+		}
+		rule, err := enabled(appName)
+		if errors.Is(err, ErrRuleNotFound) {
+			rule, err = enabled(DefaultRuleName)
+		}
+		var found *FirewallRule
+		if err == nil {
+			if rule, err = s.effectiveWith(rule, enabled); err != nil {
+				return nil, err
+			}
+			found = &rule
+		}
+		resolved[appName] = found
+		return found, nil
+	}
+
+	result := simulateResult{ByApp: map[string]simulateCounts{}}
+	env := s.evalEnv()
+	for _, entry := range s.filteredLogs(logFilter{Since: since}) {
+		if !until.IsZero() && entry.Timestamp.After(until) {
+			continue
+		}
+		rule, err := resolve(entry.AppName)
+		if err != nil {
+			return result, err
+		}
+		after := NoMatch
+		if rule != nil {
+			env.Now = entry.Timestamp
+			after, _ = rule.Explain(env, entry.RemoteDomain, parseIP(entry.RemoteIP), entry.Port, entry.Protocol)
+		}
+
+		n := entry.weight()
+		result.Evaluated += n
+		counts := result.ByApp[entry.AppName]
+		switch {
+		case after == NoMatch:
+			counts.NoMatch += n
+			result.NoMatch += n
+		case entry.Action == ActionAllowed && after == Block:
+			counts.NewlyBlocked += n
+			result.NewlyBlocked += n
+		case entry.Action == ActionBlocked && after == Allow:
+			counts.NewlyAllowed += n
+			result.NewlyAllowed += n
+		default:
+			continue
+		}
+		result.ByApp[entry.AppName] = counts
+	}
+	return result, nil
+}
+
+func (s *CentralServer) HandleSimulate(w http.ResponseWriter, r *http.Request) {
+	var req simulateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if len(req.Rules) == 0 || string(req.Rules) == "null" {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "rules is required")
+		return
+	}
+	if !req.Until.IsZero() && req.Until.Before(req.Since) {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "until must not be before since")
+		return
+	}
+	rules, err := decodeRuleBatch(bytes.NewReader(req.Rules))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "rules: "+err.Error())
+		return
+	}
+	candidates, problems := s.candidateRules(rules)
+	if len(problems) > 0 {
+		writeAPIError(w, http.StatusBadRequest, &APIError{
+			Code:    CodeValidationFailed,
+			Message: fmt.Sprintf("%d of %d rules rejected", len(problems), len(rules)),
+			Errors:  problems,
+		})
+		return
+	}
+
+	result, err := s.simulate(candidates, req.Since, req.Until)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSimulate(t *testing.T) {
+	s := newTestServer(t)
+	resolver := &gatedResolver{
+		addrs:   map[string][]net.IPAddr{"api.example.com": {{IP: net.ParseIP("192.0.2.10")}}},
+		release: make(chan struct{}),
+	}
+	close(resolver.release)
+	s.DNS = NewDNSCache(resolver, time.Minute)
+	waitForAddrs(t, s.DNS, "api.example.com")
+	h := s.Handler()
+
+	at := time.Now().UTC().Add(-time.Hour)
+	for _, entry := range []NetworkLog{
+		{AppName: "curl", RemoteDomain: "example.com", Port: 443, Protocol: "tcp", Action: ActionAllowed},
+		{AppName: "curl", RemoteDomain: "ads.example", Port: 443, Protocol: "tcp", Action: ActionAllowed},
+		{AppName: "curl", RemoteIP: "192.0.2.10", Port: 443, Protocol: "tcp", Action: ActionBlocked},
+		{AppName: "curl", RemoteDomain: "other.example", Port: 443, Protocol: "tcp", Action: ActionBlocked},
+		{AppName: "wget", RemoteDomain: "example.com", Port: 80, Protocol: "tcp", Action: ActionAllowed},
+	} {
+		entry.Timestamp = at
+		expect(t, call(t, h, "POST", "/v1/logs", entry), http.StatusCreated)
+	}
+
+	// ads.example is newly blocked, 192.0.2.10 newly allowed as an address
+	// of api.example.com, and wget, with no rule, undecided.
+	rules := `[{"app_name": "curl", "allowed_domains": ["example.com", "api.example.com"], "blocked_domains": ["ads.example"]}]`
+	rec := call(t, h, "POST", "/v1/simulate", `{"rules": `+rules+`}`)
+	expect(t, rec, http.StatusOK)
+	var got simulateResult
+	decode(t, rec, &got)
+	want := simulateResult{
+		Evaluated:      5,
+		simulateCounts: simulateCounts{NewlyBlocked: 1, NewlyAllowed: 1, NoMatch: 1},
+		ByApp: map[string]simulateCounts{
+			"curl": {NewlyBlocked: 1, NewlyAllowed: 1},
+			"wget": {NoMatch: 1},
+		},
+	}
+	if got.Evaluated != want.Evaluated || got.simulateCounts != want.simulateCounts ||
+		len(got.ByApp) != 2 || got.ByApp["curl"] != want.ByApp["curl"] || got.ByApp["wget"] != want.ByApp["wget"] {
+		t.Errorf("simulate = %+v, want %+v", got, want)
+	}
+
+	until := at.Add(-time.Minute).Format(time.RFC3339)
+	rec = call(t, h, "POST", "/v1/simulate", `{"rules": `+rules+`, "until": "`+until+`"}`)
+	if decode(t, rec, &got); got.Evaluated != 0 {
+		t.Errorf("evaluated %d logs from after until", got.Evaluated)
+	}
+	if rules, _ := s.store.List(context.Background()); len(rules) != 0 {
+		t.Errorf("simulate stored %d rules", len(rules))
+	}
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations are applied in order; PRAGMA user_version records how many have run.
+var sqliteMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS rules (
+		app_name          TEXT PRIMARY KEY,
+		allowed_domains   TEXT NOT NULL DEFAULT '[]',
+		allowed_ips       TEXT NOT NULL DEFAULT '[]',
+		allowed_protocols TEXT NOT NULL DEFAULT '[]'
+	)`,
+	`ALTER TABLE rules ADD COLUMN blocked_domains TEXT NOT NULL DEFAULT '[]'`,
+	`ALTER TABLE rules ADD COLUMN blocked_ips TEXT NOT NULL DEFAULT '[]'`,
+	`ALTER TABLE rules ADD COLUMN blocked_ports TEXT NOT NULL DEFAULT '[]'`,
+	`ALTER TABLE rules ADD COLUMN allowed_ports TEXT NOT NULL DEFAULT '[]'`,
+	`ALTER TABLE rules ADD COLUMN version INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE rules ADD COLUMN updated_at DATETIME NOT NULL DEFAULT '1970-01-01T00:00:00Z'`,
+	`CREATE TABLE audit (
+		id        INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp DATETIME NOT NULL,
+		actor     TEXT NOT NULL,
+		action    TEXT NOT NULL,
+		app_name  TEXT NOT NULL,
+		before    TEXT,
+		after     TEXT
+	)`,
+	`CREATE INDEX audit_app_name ON audit (app_name, timestamp)`,
+	`CREATE TABLE rule_history (
+		app_name TEXT NOT NULL,
+		version  INTEGER NOT NULL,
+		rule     TEXT NOT NULL,
+		PRIMARY KEY (app_name, version)
+	)`,
+	`ALTER TABLE rules ADD COLUMN schedule TEXT NOT NULL DEFAULT 'null'`,
+	`ALTER TABLE rules ADD COLUMN default_action TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE rules ADD COLUMN templates TEXT NOT NULL DEFAULT '[]'`,
+	`ALTER TABLE rules ADD COLUMN template INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE rules ADD COLUMN enabled INTEGER NOT NULL DEFAULT 1`,
+	`CREATE TABLE settings (
+		name  TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	)`,
+}
+
+type SQLiteRuleStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteRuleStore(path string) (*SQLiteRuleStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite only allows one writer; a single connection avoids SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+
+	s := &SQLiteRuleStore{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func openSQLiteReadOnly(path string) (*SQLiteRuleStore, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if version < len(sqliteMigrations) {
+		db.Close()
+		return nil, errSchemaOutdated(version, len(sqliteMigrations))
+	}
+	return &SQLiteRuleStore{db: db}, nil
+}
+
+func (s *SQLiteRuleStore) migrate() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	for i := version; i < len(sqliteMigrations); i++ {
+		if _, err := s.db.Exec(sqliteMigrations[i]); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := s.db.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteRuleStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *SQLiteRuleStore) Close() error {
+	return s.db.Close()
+}
+
+// ruleFields maps rule fields to columns. JSON fields are stored as encoded
+// TEXT; the rest are bound and scanned directly.
+var ruleFields = []struct {
+	name  string
+	json  bool
+	field func(r *FirewallRule) any
+}{
+	{"app_name", false, func(r *FirewallRule) any { return &r.AppName }},
+	{"allowed_domains", true, func(r *FirewallRule) any { return &r.AllowedDomains }},
+	{"allowed_ips", true, func(r *FirewallRule) any { return &r.AllowedIPs }},
+	{"allowed_protocols", true, func(r *FirewallRule) any { return &r.AllowedProtocols }},
+	{"blocked_domains", true, func(r *FirewallRule) any { return &r.BlockedDomains }},
+	{"blocked_ips", true, func(r *FirewallRule) any { return &r.BlockedIPs }},
+	{"blocked_ports", true, func(r *FirewallRule) any { return &r.BlockedPorts }},
+	{"allowed_ports", true, func(r *FirewallRule) any { return &r.AllowedPorts }},
+	{"schedule", true, func(r *FirewallRule) any { return &r.Schedule }},
+	{"default_action", false, func(r *FirewallRule) any { return &r.DefaultAction }},
+	{"templates", true, func(r *FirewallRule) any { return &r.Templates }},
+	{"template", false, func(r *FirewallRule) any { return &r.Template }},
+	{"enabled", false, func(r *FirewallRule) any { return &r.Enabled }},
+	{"version", false, func(r *FirewallRule) any { return &r.Version }},
+	{"updated_at", false, func(r *FirewallRule) any { return &r.UpdatedAt }},
+}
+
+var (
+	ruleColumns   = ruleColumnList()
+	ruleUpsertSQL = buildRuleUpsertSQL(func(int) string { return "?" })
+)
+
+func ruleColumnList() string {
+	names := make([]string, len(ruleFields))
+	for i, f := range ruleFields {
+		names[i] = f.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// buildRuleUpsertSQL writes the rules upsert using placeholder(n) for the
+// n-th (1-based) bind parameter. An existing row is only replaced by its
+// successor, one version on; see upsertRule.
+func buildRuleUpsertSQL(placeholder func(n int) string) string {
+	var placeholders, updates []string
+	for i, f := range ruleFields {
+		placeholders = append(placeholders, placeholder(i+1))
+		if f.name != "app_name" {
+			updates = append(updates, f.name+" = excluded."+f.name)
+		}
+	}
+	return "INSERT INTO rules (" + ruleColumnList() + ") VALUES (" + strings.Join(placeholders, ", ") + ")" +
+		" ON CONFLICT(app_name) DO UPDATE SET " + strings.Join(updates, ", ") +
+		" WHERE rules.version = excluded.version - 1"
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// upsertRule runs query, built by buildRuleUpsertSQL, for rule. The version
+// check happens in the statement itself, so a writer in another process that
+// got there first is caught even though s.mu only orders this one.
+func upsertRule(ctx context.Context, db execer, query string, rule FirewallRule) error {
+	args, err := ruleArgs(rule)
+	if err != nil {
+		return err
+	}
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %s was changed by another writer; reload it and retry", ErrVersionConflict, rule.AppName)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRule(row rowScanner) (FirewallRule, error) {
+	var rule FirewallRule
+	raw := make([]string, len(ruleFields))
+	dest := make([]any, len(ruleFields))
+	for i, f := range ruleFields {
+		if f.json {
+			dest[i] = &raw[i]
+		} else {
+			dest[i] = f.field(&rule)
+		}
+	}
+	if err := row.Scan(dest...); err != nil {
+		return rule, err
+	}
+	for i, f := range ruleFields {
+		if !f.json {
+			continue
+		}
+		if err := json.Unmarshal([]byte(raw[i]), f.field(&rule)); err != nil {
+			return rule, fmt.Errorf("decoding %s: %w", f.name, err)
+		}
+	}
+	return rule, nil
+}
+
+func (s *SQLiteRuleStore) Get(ctx context.Context, appName string) (FirewallRule, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+ruleColumns+" FROM rules WHERE app_name = ?", appName)
+	rule, err := scanRule(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rule, ErrRuleNotFound
+	}
+	return rule, err
+}
+
+func ruleArgs(rule FirewallRule) ([]any, error) {
+	args := make([]any, len(ruleFields))
+	for i, f := range ruleFields {
+		if !f.json {
+			args[i] = f.field(&rule)
+			continue
+		}
+		encoded, err := json.Marshal(f.field(&rule))
+		if err != nil {
+			return nil, err
+		}
+		args[i] = string(encoded)
+	}
+	return args, nil
+}
+
+func (s *SQLiteRuleStore) Set(ctx context.Context, rule FirewallRule) error {
+	return upsertRule(ctx, s.db, ruleUpsertSQL, rule)
+}
+
+func (s *SQLiteRuleStore) SetAll(ctx context.Context, rules []FirewallRule) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, rule := range rules {
+		if err := upsertRule(ctx, tx, ruleUpsertSQL, rule); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// encodeAuditRule stores a nil rule as SQL NULL.
+func encodeAuditRule(rule *FirewallRule) (any, error) {
+	if rule == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(rule)
+	return string(encoded), err
+}
+
+func decodeAuditRule(raw sql.NullString) (*FirewallRule, error) {
+	if !raw.Valid {
+		return nil, nil
+	}
+	var rule FirewallRule
+	if err := json.Unmarshal([]byte(raw.String), &rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (s *SQLiteRuleStore) AppendAudit(ctx context.Context, entry AuditEntry) error {
+	before, err := encodeAuditRule(entry.Before)
+	if err != nil {
+		return err
+	}
+	after, err := encodeAuditRule(entry.After)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, "INSERT INTO audit (timestamp, actor, action, app_name, before, after) VALUES (?, ?, ?, ?, ?, ?)",
+		entry.Timestamp, entry.Actor, entry.Action, entry.AppName, before, after)
+	return err
+}
+
+func (s *SQLiteRuleStore) ListAudit(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	query := "SELECT timestamp, actor, action, app_name, before, after FROM audit WHERE 1 = 1"
+	var args []any
+	if filter.AppName != "" {
+		query += " AND app_name = ?"
+		args = append(args, filter.AppName)
+	}
+	if !filter.Since.IsZero() {
+		query += " AND timestamp >= ?"
+		args = append(args, filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query += " AND timestamp <= ?"
+		args = append(args, filter.Until)
+	}
+	query += " ORDER BY id DESC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var before, after sql.NullString
+		if err := rows.Scan(&entry.Timestamp, &entry.Actor, &entry.Action, &entry.AppName, &before, &after); err != nil {
+			return nil, err
+		}
+		if entry.Before, err = decodeAuditRule(before); err != nil {
+			return nil, err
+		}
+		if entry.After, err = decodeAuditRule(after); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Delete removes the rule along with its history, so a later rule with the
+// same name starts its revisions afresh.
+func (s *SQLiteRuleStore) Delete(ctx context.Context, appName string) error {
+	return s.DeleteAll(ctx, []string{appName})
+}
+
+func (s *SQLiteRuleStore) DeleteAll(ctx context.Context, appNames []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, appName := range appNames {
+		res, err := tx.ExecContext(ctx, "DELETE FROM rules WHERE app_name = ?", appName)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("%w: %s", ErrRuleNotFound, appName)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM rule_history WHERE app_name = ?", appName); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteRuleStore) AppendHistory(ctx context.Context, rule FirewallRule, keep int) error {
+	encoded, err := json.Marshal(rule)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "INSERT OR REPLACE INTO rule_history (app_name, version, rule) VALUES (?, ?, ?)",
+		rule.AppName, rule.Version, string(encoded)); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM rule_history WHERE app_name = ? AND version NOT IN (
+		SELECT version FROM rule_history WHERE app_name = ? ORDER BY version DESC LIMIT ?)`,
+		rule.AppName, rule.AppName, keep); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteRuleStore) Setting(ctx context.Context, name string) (string, error) {
+	var value string
+	err := s.db.QueryRowContext(ctx, "SELECT value FROM settings WHERE name = ?", name).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return value, err
+}
+
+func (s *SQLiteRuleStore) PutSetting(ctx context.Context, name, value string) error {
+	_, err := s.db.ExecContext(ctx, "INSERT OR REPLACE INTO settings (name, value) VALUES (?, ?)", name, value)
+	return err
+}
+
+func (s *SQLiteRuleStore) History(ctx context.Context, appName string) ([]FirewallRule, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT rule FROM rule_history WHERE app_name = ? ORDER BY version DESC", appName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []FirewallRule{}
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		var rule FirewallRule
+		if err := json.Unmarshal([]byte(raw), &rule); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rule)
+	}
+	return revisions, rows.Err()
+}
+
+func (s *SQLiteRuleStore) List(ctx context.Context) ([]FirewallRule, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+ruleColumns+" FROM rules ORDER BY app_name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []FirewallRule{}
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
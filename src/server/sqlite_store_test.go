@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestStoreRejectsStaleWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.db")
+	first, err := NewSQLiteRuleStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	// A second handle on the same file stands in for another process.
+	second, err := NewSQLiteRuleStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	ctx := context.Background()
+
+	rule := FirewallRule{AppName: "curl", Version: 1, Enabled: true}
+	if err := first.Set(ctx, rule); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Set(ctx, rule); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("second create: %v, want a version conflict", err)
+	}
+
+	rule.Version = 2
+	rule.AllowedDomains = []string{"first.example"}
+	if err := first.Set(ctx, rule); err != nil {
+		t.Fatal(err)
+	}
+	rule.AllowedDomains = []string{"second.example"}
+	if err := second.SetAll(ctx, []FirewallRule{{AppName: "wget", Version: 1}, rule}); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("stale SetAll: %v, want a version conflict", err)
+	}
+
+	got, err := second.Get(ctx, "curl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != 2 || got.AllowedDomains[0] != "first.example" {
+		t.Errorf("stored %+v, want the first writer's version 2", got)
+	}
+	if _, err := second.Get(ctx, "wget"); !errors.Is(err, ErrRuleNotFound) {
+		t.Errorf("failed SetAll wrote wget: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const topDomainCount = 10
+
+type domainCount struct {
+	Domain string `json:"domain"`
+	Count  int    `json:"count"`
+}
+
+type logStats struct {
+	Total      int            `json:"total"`
+	ByApp      map[string]int `json:"by_app"`
+	ByAction   map[string]int `json:"by_action"`
+	ByCountry  map[string]int `json:"by_country"`
+	TopDomains []domainCount  `json:"top_domains"`
+}
+
+func computeLogStats(logs []NetworkLog, since time.Time) logStats {
+	stats := logStats{
+		ByApp:      map[string]int{},
+		ByAction:   map[string]int{ActionAllowed: 0, ActionBlocked: 0},
+		ByCountry:  map[string]int{},
+		TopDomains: []domainCount{},
+	}
+	domains := map[string]int{}
+	for _, entry := range logs {
+		if entry.Timestamp.Before(since) {
+			continue
+		}
+		n := entry.weight()
+		stats.Total += n
+		stats.ByApp[entry.AppName] += n
+		stats.ByAction[entry.Action] += n
+		if entry.Country != "" {
+			stats.ByCountry[entry.Country] += n
+		}
+		if entry.RemoteDomain != "" {
+			domains[entry.RemoteDomain] += n
+		}
+	}
+
+	for domain, count := range domains {
+		stats.TopDomains = append(stats.TopDomains, domainCount{Domain: domain, Count: count})
+	}
+	sort.Slice(stats.TopDomains, func(i, j int) bool {
+		a, b := stats.TopDomains[i], stats.TopDomains[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Domain < b.Domain
+	})
+	if len(stats.TopDomains) > topDomainCount {
+		stats.TopDomains = stats.TopDomains[:topDomainCount]
+	}
+	return stats
+}
+
+func (s *CentralServer) HandleLogStats(w http.ResponseWriter, r *http.Request) {
+	since, err := parseSince(r.URL.Query().Get("since"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "Invalid since duration")
+		return
+	}
+
+	s.logMu.RLock()
+	stats := computeLogStats(s.Logs, since)
+	s.logMu.RUnlock()
+
+	json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrRuleNotFound = errors.New("rule not found")
+
+const (
+	StorageSQLite   = "sqlite"
+	StoragePostgres = "postgres"
+)
+
+type RuleStore interface {
+	Get(ctx context.Context, appName string) (FirewallRule, error)
+	// Set stores rule, which must be the successor of the stored rule: one
+	// version on, or version 1 for a new app. Otherwise nothing is written
+	// and the error wraps ErrVersionConflict.
+	Set(ctx context.Context, rule FirewallRule) error
+	// SetAll writes every rule or none of them, checking each as Set does.
+	SetAll(ctx context.Context, rules []FirewallRule) error
+	Delete(ctx context.Context, appName string) error
+	// DeleteAll removes every named rule or none of them.
+	DeleteAll(ctx context.Context, appNames []string) error
+	List(ctx context.Context) ([]FirewallRule, error)
+	// AppendHistory saves a revision of rule, keeping only the newest keep
+	// revisions for that app. History returns them newest first.
+	AppendHistory(ctx context.Context, rule FirewallRule, keep int) error
+	History(ctx context.Context, appName string) ([]FirewallRule, error)
+	AppendAudit(ctx context.Context, entry AuditEntry) error
+	// ListAudit returns matching entries, newest first.
+	ListAudit(ctx context.Context, filter AuditFilter) ([]AuditEntry, error)
+	// Setting returns a value saved with PutSetting, or "" if there is none.
+	Setting(ctx context.Context, name string) (string, error)
+	PutSetting(ctx context.Context, name, value string) error
+
+	// Ping reports whether the backing storage is reachable.
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// OpenRuleStore opens the backend named by cfg.StorageDriver.
+func OpenRuleStore(cfg Config) (RuleStore, error) {
+	switch cfg.StorageDriver {
+	case StorageSQLite:
+		return NewSQLiteRuleStore(cfg.DBPath)
+	case StoragePostgres:
+		return NewPostgresRuleStore(cfg.DatabaseURL)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+}
+
+// OpenRuleStoreReadOnly opens the backend like OpenRuleStore but never
+// migrates it; a schema older than this build is an error instead.
+func OpenRuleStoreReadOnly(cfg Config) (RuleStore, error) {
+	switch cfg.StorageDriver {
+	case StorageSQLite:
+		return openSQLiteReadOnly(cfg.DBPath)
+	case StoragePostgres:
+		return openPostgresReadOnly(cfg.DatabaseURL)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+}
+
+func errSchemaOutdated(have, want int) error {
+	return fmt.Errorf("schema is at version %d, this build expects %d; start the server once to migrate it", have, want)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const (
+	RuleEventSet    = "set"
+	RuleEventDelete = "delete"
+)
+
+type RuleEvent struct {
+	Type    string        `json:"type"`
+	AppName string        `json:"app_name"`
+	Rule    *FirewallRule `json:"rule,omitempty"`
+}
+
+func (s *CentralServer) subscribeRules() chan RuleEvent {
+	ch := make(chan RuleEvent, 16)
+	s.subMu.Lock()
+	s.ruleSubs[ch] = struct{}{}
+	s.subMu.Unlock()
+	return ch
+}
+
+func (s *CentralServer) unsubscribeRules(ch chan RuleEvent) {
+	s.subMu.Lock()
+	if _, ok := s.ruleSubs[ch]; ok {
+		delete(s.ruleSubs, ch)
+		close(ch)
+	}
+	s.subMu.Unlock()
+}
+
+// publishRule fans an event out to every stream. A subscriber whose buffer
+// is full is dropped rather than stalling the writer or silently missing the
+// change: its channel is closed, the stream ends and the client reconnects
+// and refetches. Callers hold s.mu, so events go out in commit order.
+func (s *CentralServer) publishRule(event RuleEvent) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	for ch := range s.ruleSubs {
+		select {
+		case ch <- event:
+		default:
+			delete(s.ruleSubs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (s *CentralServer) HandleRuleStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, CodeInternal, "Streaming unsupported")
+		return
+	}
+
+	ch := s.subscribeRules()
+	defer s.unsubscribeRules(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.closing:
+			return
+		case event, ok := <-ch:
+			if !ok {
+				// Dropped for falling behind; ending the stream tells the
+				// client to reconnect and resync.
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
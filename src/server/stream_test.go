@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readEvent returns the next event's type and data from an SSE stream.
+func readEvent(t *testing.T, r *bufio.Reader) (string, string) {
+	t.Helper()
+	var event, data string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "" && event != "":
+			return event, data
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestRuleStream(t *testing.T) {
+	s := newTestServer(t)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/v1/rules/stream", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	stream := bufio.NewReader(resp.Body)
+
+	h := s.Handler()
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"example.com"}, Enabled: true})
+	event, data := readEvent(t, stream)
+	var got RuleEvent
+	if err := json.Unmarshal([]byte(data), &got); err != nil {
+		t.Fatal(err)
+	}
+	if event != RuleEventSet || got.AppName != "curl" || got.Rule == nil || got.Rule.Version != 1 {
+		t.Errorf("event %s: %+v", event, got)
+	}
+
+	expect(t, call(t, h, "DELETE", "/v1/rule/curl", nil), http.StatusNoContent)
+	if event, data = readEvent(t, stream); event != RuleEventDelete || !strings.Contains(data, `"app_name":"curl"`) {
+		t.Errorf("event %s: %s", event, data)
+	}
+}
+
+func TestRuleStreamDropsSlowSubscriber(t *testing.T) {
+	s := newTestServer(t)
+	ch := s.subscribeRules()
+	defer s.unsubscribeRules(ch)
+
+	for i := 0; i <= cap(ch); i++ {
+		s.publishRule(RuleEvent{Type: RuleEventDelete, AppName: "curl"})
+	}
+	for i := 0; i < cap(ch); i++ {
+		<-ch
+	}
+	if _, ok := <-ch; ok {
+		t.Fatal("subscriber that fell behind is still subscribed")
+	}
+}
+
+func TestRuleEventsInVersionOrder(t *testing.T) {
+	s := newTestServer(t)
+	ch := s.subscribeRules()
+	defer s.unsubscribeRules(ch)
+
+	writers := cap(ch)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.SetRule(context.Background(), FirewallRule{AppName: "curl", Enabled: true}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	for want := 1; want <= writers; want++ {
+		if event := <-ch; event.Rule.Version != want {
+			t.Fatalf("event %d carries version %d", want, event.Rule.Version)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+var ErrTemplateInUse = errors.New("template in use")
+
+// checkTemplates verifies that every template rule names, directly or
+// through other templates, exists, is marked as a template and doesn't lead
+// back to rule. get looks up the other rules.
+func checkTemplates(rule FirewallRule, get func(appName string) (FirewallRule, error)) error {
+	visited := map[string]bool{}
+	var visit func(names, path []string) error
+	visit = func(names, path []string) error {
+		for _, name := range names {
+			if slices.Contains(path, name) {
+				return fmt.Errorf("%w: template cycle %s -> %s", ErrInvalidRule, strings.Join(path, " -> "), name)
+			}
+			if visited[name] {
+				continue
+			}
+			visited[name] = true
+			tmpl, err := get(name)
+			if errors.Is(err, ErrRuleNotFound) {
+				return fmt.Errorf("%w: unknown template %q", ErrInvalidRule, name)
+			}
+			if err != nil {
+				return err
+			}
+			if !tmpl.Template {
+				return fmt.Errorf("%w: %q is not a template", ErrInvalidRule, name)
+			}
+			if err := visit(tmpl.Templates, append(path, name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return visit(rule.Templates, []string{rule.AppName})
+}
+
+// mergeRuleLists appends src's allow and block lists to dst's, skipping
+// entries dst already has.
+func mergeRuleLists(dst *FirewallRule, src FirewallRule) {
+	dst.AllowedDomains = appendMissing(dst.AllowedDomains, src.AllowedDomains...)
+	dst.AllowedIPs = appendMissing(dst.AllowedIPs, src.AllowedIPs...)
+	dst.AllowedProtocols = appendMissing(dst.AllowedProtocols, src.AllowedProtocols...)
+	dst.AllowedPorts = appendMissing(dst.AllowedPorts, src.AllowedPorts...)
+	dst.BlockedDomains = appendMissing(dst.BlockedDomains, src.BlockedDomains...)
+	dst.BlockedIPs = appendMissing(dst.BlockedIPs, src.BlockedIPs...)
+	dst.BlockedPorts = appendMissing(dst.BlockedPorts, src.BlockedPorts...)
+}
+
+func appendMissing[T comparable](list []T, items ...T) []T {
+	for _, item := range items {
+		if !slices.Contains(list, item) {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// effectiveLocked returns rule with the lists of all its templates, at any
+// depth, merged in and server variables substituted. Schedule and
+// DefaultAction stay the rule's own. Templates deleted since the rule was
+// saved are skipped. Callers hold s.mu.
+func (s *CentralServer) effectiveLocked(ctx context.Context, rule FirewallRule) (FirewallRule, error) {
+	return s.effectiveWith(rule, func(name string) (FirewallRule, error) { return s.enabledRuleLocked(ctx, name) })
+}
+
+// effectiveWith is effectiveLocked with templates looked up by get, which
+// reports missing or disabled rules as ErrRuleNotFound.
+func (s *CentralServer) effectiveWith(rule FirewallRule, get func(appName string) (FirewallRule, error)) (FirewallRule, error) {
+	effective := rule
+	seen := map[string]bool{rule.AppName: true}
+	queue := slices.Clone(rule.Templates)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		tmpl, err := get(name)
+		if errors.Is(err, ErrRuleNotFound) {
+			continue
+		}
+		if err != nil {
+			return rule, err
+		}
+		mergeRuleLists(&effective, tmpl)
+		queue = append(queue, tmpl.Templates...)
+	}
+	// Writes reject unknown variables, but one may have been dropped from
+	// the config since; its entries stay unresolved and match nothing.
+	effective, _ = effective.substitute(s.Variables)
+	return effective, nil
+}
+
+// EffectiveRule resolves appName like ResolveRule and merges in its templates.
+func (s *CentralServer) EffectiveRule(ctx context.Context, appName string) (FirewallRule, bool, error) {
+	rule, isDefault, err := s.ResolveRule(ctx, appName)
+	if err != nil {
+		return rule, isDefault, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rule, err = s.effectiveLocked(ctx, rule)
+	return rule, isDefault, err
+}
+
+// templateUsersLocked returns the apps whose rules name template directly.
+// Callers hold s.mu.
+func (s *CentralServer) templateUsersLocked(ctx context.Context, template string) ([]string, error) {
+	rules, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var users []string
+	for _, rule := range rules {
+		if slices.Contains(rule.Templates, template) {
+			users = append(users, rule.AppName)
+		}
+	}
+	return users, nil
+}
+
+func (s *CentralServer) HandleEffectiveRule(w http.ResponseWriter, r *http.Request) {
+	rule, isDefault, err := s.EffectiveRule(r.Context(), mux.Vars(r)["app_name"])
+	if err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	writeETagged(w, r, resolvedRule{FirewallRule: rule, Default: isDefault})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTemplateInheritance(t *testing.T) {
+	h := newTestServer(t).Handler()
+	putRule(t, h, FirewallRule{AppName: "base", AllowedDomains: []string{"*.corp.example"}, BlockedIPs: []string{"10.0.0.5"}, Template: true, Enabled: true})
+	putRule(t, h, FirewallRule{AppName: "web", AllowedDomains: []string{"cdn.example"}, Templates: []string{"base"}, Template: true, Enabled: true})
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedIPs: []string{"10.0.0.0/8"}, Templates: []string{"web"}, Enabled: true})
+
+	rec := call(t, h, "GET", "/v1/rule/curl/effective", nil)
+	expect(t, rec, http.StatusOK)
+	var effective FirewallRule
+	decode(t, rec, &effective)
+	if !slices.Equal(effective.AllowedDomains, []string{"cdn.example", "*.corp.example"}) || !slices.Equal(effective.BlockedIPs, []string{"10.0.0.5"}) {
+		t.Errorf("effective rule = domains %v, blocked %v", effective.AllowedDomains, effective.BlockedIPs)
+	}
+
+	var stored FirewallRule
+	decode(t, call(t, h, "GET", "/v1/rule/curl", nil), &stored)
+	if len(stored.AllowedDomains) != 0 {
+		t.Errorf("stored rule gained inherited domains %v", stored.AllowedDomains)
+	}
+
+	for _, tt := range []struct {
+		req  evaluateRequest
+		want Decision
+	}{
+		{evaluateRequest{Domain: "git.corp.example", Port: 443}, Allow},
+		{evaluateRequest{Domain: "cdn.example", Port: 443}, Allow},
+		{evaluateRequest{IP: "10.0.0.5", Port: 443}, Block},
+	} {
+		var got evaluateResponse
+		decode(t, call(t, h, "POST", "/v1/rule/curl/evaluate", tt.req), &got)
+		if got.Decision != tt.want {
+			t.Errorf("evaluate %+v = %s, want %s", tt.req, got.Decision, tt.want)
+		}
+	}
+
+	rec = call(t, h, "DELETE", "/v1/rule/web", nil)
+	expect(t, rec, http.StatusConflict)
+	var apiErr APIError
+	decode(t, rec, &apiErr)
+	if apiErr.Code != CodeTemplateInUse || !strings.Contains(apiErr.Message, "curl") {
+		t.Errorf("deleting a used template: %+v", apiErr)
+	}
+}
+
+func TestTemplateReferencesRejected(t *testing.T) {
+	h := newTestServer(t).Handler()
+	putRule(t, h, FirewallRule{AppName: "a", Template: true, Enabled: true})
+	putRule(t, h, FirewallRule{AppName: "b", Templates: []string{"a"}, Template: true, Enabled: true})
+	putRule(t, h, FirewallRule{AppName: "plain", Enabled: true})
+
+	tests := []struct {
+		name string
+		rule FirewallRule
+		want string
+	}{
+		{"cycle", FirewallRule{AppName: "a", Templates: []string{"b"}, Template: true}, "template cycle a -> b -> a"},
+		{"self", FirewallRule{AppName: "a", Templates: []string{"a"}, Template: true}, "template cycle a -> a"},
+		{"unknown", FirewallRule{AppName: "curl", Templates: []string{"nope"}}, `unknown template "nope"`},
+		{"not a template", FirewallRule{AppName: "curl", Templates: []string{"plain"}}, `"plain" is not a template`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := call(t, h, "POST", "/v1/rule", tt.rule)
+			expect(t, rec, http.StatusBadRequest)
+			var apiErr APIError
+			decode(t, rec, &apiErr)
+			if apiErr.Code != CodeValidationFailed || !strings.Contains(apiErr.Message, tt.want) {
+				t.Errorf("error = %+v, want VALIDATION_FAILED with %q", apiErr, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const exportFormatVersion = 1
+
+// RuleExport is the backup document served by /rules/export. /rules/import
+// accepts it as well as a bare array of rules.
+type RuleExport struct {
+	Version    int            `json:"version"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Rules      []FirewallRule `json:"rules"`
+}
+
+func (s *CentralServer) HandleExportRules(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	rules, err := s.store.List(r.Context())
+	s.mu.RUnlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+
+	export := RuleExport{Version: exportFormatVersion, ExportedAt: time.Now().UTC(), Rules: rules}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="rules-export.json"`)
+	json.NewEncoder(w).Encode(export)
+}
+
+func decodeRuleBatch(body io.Reader) ([]FirewallRule, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		return nil, err
+	}
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '{' {
+		var export RuleExport
+		if err := json.Unmarshal(raw, &export); err != nil {
+			return nil, err
+		}
+		if export.Version != exportFormatVersion {
+			return nil, fmt.Errorf("unsupported export version %d", export.Version)
+		}
+		return export.Rules, nil
+	}
+	var rules []FirewallRule
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+type importError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// importRules validates and stores the batch as a unit under the write lock.
+// On validation failure nothing is written and every problem is returned.
+func (s *CentralServer) importRules(r *http.Request, rules []FirewallRule) ([]importError, error) {
+	var problems []importError
+	seen := make(map[string]int, len(rules))
+	for i, rule := range rules {
+		if err := s.validateRule(rule); err != nil {
+			problems = append(problems, importError{Index: i, Error: err.Error()})
+			continue
+		}
+		if first, ok := seen[rule.AppName]; ok {
+			problems = append(problems, importError{Index: i, Error: fmt.Sprintf("duplicate app_name %q (first at index %d)", rule.AppName, first)})
+			continue
+		}
+		seen[rule.AppName] = i
+	}
+	if len(problems) > 0 {
+		return problems, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Templates may be defined in the same batch as the rules using them.
+	get := func(appName string) (FirewallRule, error) {
+		if i, ok := seen[appName]; ok {
+			return rules[i], nil
+		}
+		return s.store.Get(r.Context(), appName)
+	}
+	for i, rule := range rules {
+		err := checkTemplates(rule, get)
+		if errors.Is(err, ErrInvalidRule) {
+			problems = append(problems, importError{Index: i, Error: err.Error()})
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if len(problems) > 0 {
+		return problems, nil
+	}
+
+	if err := s.checkWritableLocked(); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	previous := make([]FirewallRule, len(rules))
+	for i := range rules {
+		current, err := s.store.Get(r.Context(), rules[i].AppName)
+		if err != nil && !errors.Is(err, ErrRuleNotFound) {
+			return nil, err
+		}
+		previous[i] = current
+		rules[i].prepareSuccessor(current)
+		rules[i].Version = current.Version + 1
+		rules[i].UpdatedAt = now
+	}
+	if err := s.store.SetAll(r.Context(), rules); err != nil {
+		return nil, err
+	}
+	for i := range rules {
+		s.recordHistoryLocked(r.Context(), rules[i])
+		if previous[i].AppName == "" {
+			s.recordAuditLocked(r.Context(), AuditCreate, nil, &rules[i])
+		} else {
+			s.recordAuditLocked(r.Context(), AuditUpdate, &previous[i], &rules[i])
+		}
+		s.ruleStoredLocked(rules[i])
+	}
+	return nil, nil
+}
+
+func (s *CentralServer) HandleImportRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := decodeRuleBatch(r.Body)
+	if err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	problems, err := s.importRules(r, rules)
+	if err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	if len(problems) > 0 {
+		writeAPIError(w, http.StatusBadRequest, &APIError{
+			Code:    CodeValidationFailed,
+			Message: fmt.Sprintf("%d of %d rules rejected; nothing was imported", len(problems), len(rules)),
+			Errors:  problems,
+		})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"imported": len(rules)})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	h := newTestServer(t).Handler()
+	putRule(t, h, FirewallRule{AppName: "curl", AllowedDomains: []string{"*.example.com"}, AllowedPorts: []PortRange{{443, 443}}, Enabled: true})
+	putRule(t, h, FirewallRule{AppName: "wget", AllowedIPs: []string{"10.0.0.0/8"}, BlockedIPs: []string{"10.0.0.5"}, DefaultAction: Allow, Enabled: true})
+
+	rec := call(t, h, "GET", "/v1/rules/export", nil)
+	expect(t, rec, http.StatusOK)
+	backup := rec.Body.String()
+	var export RuleExport
+	decode(t, rec, &export)
+	if export.Version != exportFormatVersion || len(export.Rules) != 2 {
+		t.Fatalf("export = %+v", export)
+	}
+
+	for _, rule := range export.Rules {
+		expect(t, call(t, h, "DELETE", "/v1/rule/"+rule.AppName, nil), http.StatusNoContent)
+	}
+	rec = call(t, h, "GET", "/v1/rules", nil)
+	var rules []FirewallRule
+	decode(t, rec, &rules)
+	if len(rules) != 0 {
+		t.Fatalf("%d rules left after clearing", len(rules))
+	}
+
+	expect(t, call(t, h, "POST", "/v1/rules/import", backup), http.StatusOK)
+	rec = call(t, h, "GET", "/v1/rules", nil)
+	decode(t, rec, &rules)
+	for i := range rules {
+		rules[i].UpdatedAt = time.Time{}
+		export.Rules[i].UpdatedAt = time.Time{}
+	}
+	if !reflect.DeepEqual(rules, export.Rules) {
+		t.Errorf("restored rules differ:\n got %+v\nwant %+v", rules, export.Rules)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// runValidate checks cfg and a rule set without serving anything, writing a
+// line per problem and a summary to out. The rules come from files, each an
+// export document or a bare array, or from the configured store, opened
+// without migrating it, when no files are given. Invalid rules fail the run;
+// allow entries overridden by blocks are only reported. It returns the
+// process exit code.
+func runValidate(cfg Config, files []string, out io.Writer) int {
+	type sourced struct {
+		source string
+		rule   FirewallRule
+	}
+	var rules []sourced
+	failed := 0
+
+	if len(files) > 0 {
+		for _, path := range files {
+			f, err := os.Open(path)
+			if err != nil {
+				fmt.Fprintf(out, "FAIL %v\n", err)
+				failed++
+				continue
+			}
+			batch, err := decodeRuleBatch(f)
+			f.Close()
+			if err != nil {
+				fmt.Fprintf(out, "FAIL %s: %v\n", path, err)
+				failed++
+				continue
+			}
+			for _, rule := range batch {
+				rules = append(rules, sourced{path, rule})
+			}
+		}
+	} else {
+		store, err := OpenRuleStoreReadOnly(cfg)
+		if err != nil {
+			fmt.Fprintf(out, "FAIL opening %s store: %v\n", cfg.StorageDriver, err)
+			return 1
+		}
+		stored, err := store.List(context.Background())
+		store.Close()
+		if err != nil {
+			fmt.Fprintf(out, "FAIL listing rules: %v\n", err)
+			return 1
+		}
+		for _, rule := range stored {
+			rules = append(rules, sourced{cfg.StorageDriver, rule})
+		}
+	}
+
+	byName := make(map[string]FirewallRule, len(rules))
+	for _, r := range rules {
+		byName[r.rule.AppName] = r.rule
+	}
+	get := func(appName string) (FirewallRule, error) {
+		if rule, ok := byName[appName]; ok {
+			return rule, nil
+		}
+		return FirewallRule{}, ErrRuleNotFound
+	}
+
+	s := &CentralServer{Variables: cfg.Variables}
+	seen := make(map[string]string, len(rules))
+	warned := 0
+	for _, r := range rules {
+		name := r.rule.AppName
+		var problems []string
+		if first, dup := seen[name]; dup {
+			problems = append(problems, "duplicate app_name (also in "+first+")")
+		}
+		seen[name] = r.source
+		if err := s.validateRule(r.rule); err != nil {
+			problems = append(problems, err.Error())
+		}
+		if err := checkTemplates(r.rule, get); err != nil {
+			problems = append(problems, err.Error())
+		}
+		if len(problems) > 0 {
+			fmt.Fprintf(out, "FAIL %s %q: %s\n", r.source, name, strings.Join(problems, "; "))
+			failed++
+		}
+		for _, c := range r.rule.Conflicts() {
+			fmt.Fprintf(out, "WARN %s %q: allowed %s %s is overridden by blocked %s\n", r.source, name, c.Kind, c.Allowed, c.Blocked)
+			warned++
+		}
+	}
+
+	fmt.Fprintf(out, "%d rules checked: %d problems, %d conflict warnings\n", len(rules), failed, warned)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
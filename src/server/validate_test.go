@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateStoreReportsEveryProblem(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "firewall.db")
+	store, err := NewSQLiteRuleStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Written straight to the store, as an older or hand-edited database
+	// might hold them; the API would refuse the first three.
+	for _, rule := range []FirewallRule{
+		{AppName: "curl", AllowedIPs: []string{"10.0.0.0/33"}, Enabled: true, Version: 1},
+		{AppName: "wget", BlockedPorts: []int{70000}, Enabled: true, Version: 1},
+		{AppName: "git", Templates: []string{"missing"}, Enabled: true, Version: 1},
+		{AppName: "ssh", AllowedIPs: []string{"10.0.0.0/8"}, BlockedIPs: []string{"10.1.0.0/16"}, Enabled: true, Version: 1},
+	} {
+		if err := store.Set(context.Background(), rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Close()
+
+	var out strings.Builder
+	code := runValidate(Config{StorageDriver: "sqlite", DBPath: path}, nil, &out)
+	if code == 0 {
+		t.Errorf("exit code 0 for an invalid store:\n%s", &out)
+	}
+	for _, want := range []string{`FAIL sqlite "curl"`, `FAIL sqlite "wget"`, `FAIL sqlite "git"`, `WARN sqlite "ssh"`, "4 rules checked: 3 problems, 1 conflict warnings"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, &out)
+		}
+	}
+	if strings.Contains(out.String(), `FAIL sqlite "ssh"`) {
+		t.Errorf("a conflict failed the run:\n%s", &out)
+	}
+}
+
+func TestValidateRuleFiles(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.json")
+	bad := filepath.Join(dir, "bad.json")
+	os.WriteFile(good, []byte(`[{"app_name": "curl", "allowed_ips": ["10.0.0.0/8"]}]`), 0o600)
+	os.WriteFile(bad, []byte(`[{"app_name": "wget", "allowed_ips": ["10.0.0.0/33"]}]`), 0o600)
+
+	var out strings.Builder
+	if code := runValidate(Config{}, []string{good}, &out); code != 0 {
+		t.Errorf("exit code %d for valid rules:\n%s", code, &out)
+	}
+	out.Reset()
+	if code := runValidate(Config{}, []string{good, bad, filepath.Join(dir, "missing.json")}, &out); code == 0 {
+		t.Errorf("exit code 0 with an invalid CIDR and a missing file:\n%s", &out)
+	}
+	if !strings.Contains(out.String(), `FAIL `+bad+` "wget"`) || !strings.Contains(out.String(), "missing.json") {
+		t.Errorf("output does not name both problems:\n%s", &out)
+	}
+}
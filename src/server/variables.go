@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// placeholderRE matches a ${NAME} reference to a server variable.
+var placeholderRE = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+var variableNameRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// substituteList replaces placeholders in each entry. Entries naming an
+// unknown variable are kept as written and described in problems.
+func substituteList(field string, entries []string, vars map[string]string, problems *[]string) []string {
+	if entries == nil {
+		return nil
+	}
+	out := make([]string, len(entries))
+	for i, entry := range entries {
+		var missing []string
+		out[i] = placeholderRE.ReplaceAllStringFunc(entry, func(ref string) string {
+			value, ok := vars[ref[2:len(ref)-1]]
+			if !ok {
+				missing = append(missing, ref)
+			}
+			return value
+		})
+		if len(missing) > 0 {
+			out[i] = entry
+			*problems = append(*problems, fmt.Sprintf("%s[%d]: undefined variable %s", field, i, strings.Join(missing, ", ")))
+		}
+	}
+	return out
+}
+
+// substitute returns r with ${NAME} placeholders in its allowed domains and
+// IPs replaced from vars. On error the result still carries every entry
+// that could be resolved.
+func (r FirewallRule) substitute(vars map[string]string) (FirewallRule, error) {
+	var problems []string
+	r.AllowedDomains = substituteList("allowed_domains", r.AllowedDomains, vars, &problems)
+	r.AllowedIPs = substituteList("allowed_ips", r.AllowedIPs, vars, &problems)
+	if len(problems) > 0 {
+		return r, errors.New(strings.Join(problems, "; "))
+	}
+	return r, nil
+}
+
+// validateRule validates rule as it will be evaluated, with the server's
+// variables substituted.
+func (s *CentralServer) validateRule(rule FirewallRule) error {
+	resolved, err := rule.substitute(s.Variables)
+	if err != nil {
+		return err
+	}
+	return resolved.Validate()
+}
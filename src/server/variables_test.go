@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestVariableSubstitution(t *testing.T) {
+	s := newTestServer(t)
+	s.Variables = map[string]string{"INTERNAL_CIDR": "10.0.0.0/8", "CORP_DOMAIN": "corp.example"}
+	h := s.Handler()
+	stored := putRule(t, h, FirewallRule{
+		AppName:        "curl",
+		AllowedIPs:     []string{"${INTERNAL_CIDR}"},
+		AllowedDomains: []string{"api.${CORP_DOMAIN}"},
+		Enabled:        true,
+	})
+	if !slices.Equal(stored.AllowedIPs, []string{"${INTERNAL_CIDR}"}) {
+		t.Errorf("stored allowed_ips = %v, want the placeholder kept", stored.AllowedIPs)
+	}
+
+	rec := call(t, h, "GET", "/v1/rule/curl/effective", nil)
+	expect(t, rec, http.StatusOK)
+	var effective FirewallRule
+	decode(t, rec, &effective)
+	if !slices.Equal(effective.AllowedIPs, []string{"10.0.0.0/8"}) || !slices.Equal(effective.AllowedDomains, []string{"api.corp.example"}) {
+		t.Errorf("effective rule = ips %v, domains %v; want the variables substituted", effective.AllowedIPs, effective.AllowedDomains)
+	}
+
+	rec = call(t, h, "POST", "/v1/decision", decisionRequest{AppName: "curl", IP: "10.1.2.3", Port: 443, Protocol: "tcp"})
+	var got decisionResponse
+	if decode(t, rec, &got); got.Decision != Allow {
+		t.Errorf("10.1.2.3 decided %s, want %s through ${INTERNAL_CIDR}", got.Decision, Allow)
+	}
+
+	s.Variables = map[string]string{"INTERNAL_CIDR": "192.168.0.0/16", "CORP_DOMAIN": "corp.example"}
+	decode(t, call(t, h, "GET", "/v1/rule/curl/effective", nil), &effective)
+	if !slices.Equal(effective.AllowedIPs, []string{"192.168.0.0/16"}) {
+		t.Errorf("after a variable change, effective allowed_ips = %v", effective.AllowedIPs)
+	}
+
+	rule := FirewallRule{AppName: "wget", AllowedIPs: []string{"${UNDEFINED}"}, Enabled: true}
+	expect(t, call(t, h, "POST", "/v1/rule", rule), http.StatusBadRequest)
+}
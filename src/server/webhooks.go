@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const (
+	webhookQueueSize = 256
+	webhookTimeout   = 5 * time.Second
+	webhookAttempts  = 3
+	webhookBackoff   = time.Second
+)
+
+// Webhook is an endpoint told about blocked connections. Apps limits it to
+// those apps; empty means every app.
+type Webhook struct {
+	URL  string   `json:"url"`
+	Apps []string `json:"apps,omitempty"`
+}
+
+func (h Webhook) wants(appName string) bool {
+	return len(h.Apps) == 0 || slices.Contains(h.Apps, appName)
+}
+
+const WebhookEventBlocked = "connection_blocked"
+
+type webhookPayload struct {
+	Event string     `json:"event"`
+	Log   NetworkLog `json:"log"`
+}
+
+// webhookDispatcher delivers notifications off the ingestion path. When its
+// queue is full, new notifications are dropped rather than slowing agents.
+type webhookDispatcher struct {
+	hooks  []Webhook
+	client *http.Client
+	queue  chan NetworkLog
+}
+
+func newWebhookDispatcher(hooks []Webhook) *webhookDispatcher {
+	return &webhookDispatcher{
+		hooks:  hooks,
+		client: &http.Client{Timeout: webhookTimeout},
+		queue:  make(chan NetworkLog, webhookQueueSize),
+	}
+}
+
+// notify queues entry for delivery. It never blocks.
+func (d *webhookDispatcher) notify(entry NetworkLog) {
+	select {
+	case d.queue <- entry:
+	default:
+		webhookDeliveriesTotal.WithLabelValues("dropped").Inc()
+		slog.Warn("webhook queue full; dropping notification", "app_name", entry.AppName)
+	}
+}
+
+// StartWebhooks delivers queued notifications until ctx is done. It does
+// nothing when no webhooks are configured.
+func (s *CentralServer) StartWebhooks(ctx context.Context) {
+	d := s.webhooks
+	if d == nil {
+		return
+	}
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case entry := <-d.queue:
+				for _, hook := range d.hooks {
+					if hook.wants(entry.AppName) {
+						d.deliver(ctx, hook, entry)
+					}
+				}
+			}
+		}
+	}()
+}
+
+// deliver POSTs the notification, retrying failures and non-2xx answers with
+// a growing pause.
+func (d *webhookDispatcher) deliver(ctx context.Context, hook Webhook, entry NetworkLog) {
+	body, err := json.Marshal(webhookPayload{Event: WebhookEventBlocked, Log: entry})
+	if err != nil {
+		return
+	}
+	for attempt := 1; ; attempt++ {
+		err = d.post(ctx, hook.URL, body)
+		if err == nil {
+			webhookDeliveriesTotal.WithLabelValues("delivered").Inc()
+			return
+		}
+		if attempt == webhookAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(attempt) * webhookBackoff):
+		}
+	}
+	webhookDeliveriesTotal.WithLabelValues("failed").Inc()
+	slog.Warn("webhook delivery failed", "url", hook.URL, "app_name", entry.AppName, "attempts", webhookAttempts, "err", err)
+}
+
+func (d *webhookDispatcher) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotifiesBlockedLogs(t *testing.T) {
+	payloads := make(chan webhookPayload, 10)
+	var attempts atomic.Int32
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first delivery fails, to exercise the retry.
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		var p webhookPayload
+		if r.Header.Get("Content-Type") != "application/json" || json.NewDecoder(r.Body).Decode(&p) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		payloads <- p
+	}))
+	defer hook.Close()
+
+	s := newTestServer(t)
+	s.webhooks = newWebhookDispatcher([]Webhook{{URL: hook.URL, Apps: []string{"curl"}}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.StartWebhooks(ctx)
+
+	h := s.Handler()
+	for _, entry := range []NetworkLog{
+		{AppName: "curl", RemoteDomain: "allowed.example", Action: ActionAllowed},
+		{AppName: "wget", RemoteDomain: "other-app.example", Action: ActionBlocked},
+		{AppName: "curl", RemoteDomain: "ads.example", Port: 443, Action: ActionBlocked},
+	} {
+		expect(t, call(t, h, "POST", "/v1/logs", entry), http.StatusCreated)
+	}
+
+	select {
+	case p := <-payloads:
+		if p.Event != WebhookEventBlocked || p.Log.AppName != "curl" || p.Log.RemoteDomain != "ads.example" || p.Log.ID != 3 {
+			t.Errorf("payload = %+v", p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no webhook delivered")
+	}
+	select {
+	case p := <-payloads:
+		t.Errorf("unexpected second payload %+v", p)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if n := attempts.Load(); n != 2 {
+		t.Errorf("%d delivery attempts, want 2", n)
+	}
+}